@@ -0,0 +1,400 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/Genez-io/genezio-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// inactiveGitProvidersAnnotation keeps the blocks of the git providers other than the
+// selected one, which v1beta1 does not represent, so that they survive a round trip
+const inactiveGitProvidersAnnotation = "init.genezio.com/v1alpha1-inactive-git-providers"
+
+// inactiveGitProviders is the content of inactiveGitProvidersAnnotation
+type inactiveGitProviders struct {
+	Gitea     *GiteaProvider     `json:"gitea,omitempty"`
+	GitHub    *GitHubProvider    `json:"github,omitempty"`
+	GitLab    *GitLabProvider    `json:"gitlab,omitempty"`
+	Bitbucket *BitbucketProvider `json:"bitbucket,omitempty"`
+}
+
+var _ conversion.Convertible = &GenezioManager{}
+
+// ConvertTo converts this GenezioManager to the Hub version (v1beta1)
+func (src *GenezioManager) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.GenezioManager)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	delete(dst.Annotations, inactiveGitProvidersAnnotation)
+
+	gitConfig, inactive := convertGitConfigTo(src.Spec.GitConfig)
+	if inactive != (inactiveGitProviders{}) {
+		raw, err := json.Marshal(inactive)
+		if err != nil {
+			return fmt.Errorf("failed to keep the inactive git providers of %s/%s: %w", src.Namespace, src.Name, err)
+		}
+		if dst.Annotations == nil {
+			dst.Annotations = map[string]string{}
+		}
+		dst.Annotations[inactiveGitProvidersAnnotation] = string(raw)
+	}
+
+	dst.Spec = v1beta1.GenezioManagerSpec{
+		ArgoCDConfig: convertArgoCDConfigTo(src.Spec.ArgoCDConfig),
+		GitConfig:    gitConfig,
+		ContainerRegistryConfig: v1beta1.ContainerRegistryConfig{
+			URL:               src.Spec.ContainerRegistryConfig.URL,
+			Username:          src.Spec.ContainerRegistryConfig.Username,
+			Password:          src.Spec.ContainerRegistryConfig.Password,
+			PasswordSecretRef: secretRefTo(src.Spec.ContainerRegistryConfig.PasswordSecretName, src.Spec.ContainerRegistryConfig.PasswordSecretKey),
+			PullSecretReplication: (*v1beta1.PullSecretReplication)(
+				src.Spec.ContainerRegistryConfig.PullSecretReplication.DeepCopy()),
+		},
+		Region:        src.Spec.Region,
+		ContainerPort: src.Spec.ContainerPort,
+		ChartRepo:     src.Spec.ChartRepo,
+		ChartRev:      src.Spec.ChartRev,
+		Service:       v1beta1.ServiceConfig(*src.Spec.Service.DeepCopy()),
+		Ingress:       (*v1beta1.IngressConfig)(src.Spec.Ingress.DeepCopy()),
+		CD: v1beta1.CDConfig{
+			Engine: src.Spec.CD.Engine,
+			Flux:   (*v1beta1.FluxConfig)(src.Spec.CD.Flux.DeepCopy()),
+		},
+		Image:                 (*v1beta1.ImageConfig)(src.Spec.Image.DeepCopy()),
+		Domain:                src.Spec.Domain,
+		WildcardTLSSecretName: src.Spec.WildcardTLSSecretName,
+		AppsIngressClassName:  src.Spec.AppsIngressClassName,
+	}
+	dst.Status = convertStatusTo(src.Status)
+	return nil
+}
+
+// ConvertFrom converts the Hub version (v1beta1) to this GenezioManager
+func (dst *GenezioManager) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.GenezioManager)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	dst.Spec = GenezioManagerSpec{
+		ArgoCDConfig: convertArgoCDConfigFrom(src.Spec.ArgoCDConfig),
+		GitConfig:    convertGitConfigFrom(src.Spec.GitConfig),
+		ContainerRegistryConfig: ContainerRegistryConfig{
+			URL:      src.Spec.ContainerRegistryConfig.URL,
+			Username: src.Spec.ContainerRegistryConfig.Username,
+			Password: src.Spec.ContainerRegistryConfig.Password,
+			PullSecretReplication: (*PullSecretReplication)(
+				src.Spec.ContainerRegistryConfig.PullSecretReplication.DeepCopy()),
+		},
+		Region:        src.Spec.Region,
+		ContainerPort: src.Spec.ContainerPort,
+		ChartRepo:     src.Spec.ChartRepo,
+		ChartRev:      src.Spec.ChartRev,
+		Service:       ServiceConfig(*src.Spec.Service.DeepCopy()),
+		Ingress:       (*IngressConfig)(src.Spec.Ingress.DeepCopy()),
+		CD: CDConfig{
+			Engine: src.Spec.CD.Engine,
+			Flux:   (*FluxConfig)(src.Spec.CD.Flux.DeepCopy()),
+		},
+		Image:                 (*ImageConfig)(src.Spec.Image.DeepCopy()),
+		Domain:                src.Spec.Domain,
+		WildcardTLSSecretName: src.Spec.WildcardTLSSecretName,
+		AppsIngressClassName:  src.Spec.AppsIngressClassName,
+	}
+	dst.Spec.ContainerRegistryConfig.PasswordSecretName, dst.Spec.ContainerRegistryConfig.PasswordSecretKey =
+		secretRefFrom(src.Spec.ContainerRegistryConfig.PasswordSecretRef)
+
+	if raw, ok := dst.Annotations[inactiveGitProvidersAnnotation]; ok {
+		inactive := inactiveGitProviders{}
+		if err := json.Unmarshal([]byte(raw), &inactive); err != nil {
+			return fmt.Errorf("failed to restore the inactive git providers of %s/%s: %w", src.Namespace, src.Name, err)
+		}
+		// The block of the selected provider wins over a stale copy
+		gitConfig := &dst.Spec.GitConfig
+		if inactive.Gitea != nil && src.Spec.GitConfig.Gitea == nil {
+			gitConfig.Gitea = *inactive.Gitea
+		}
+		if inactive.GitHub != nil && src.Spec.GitConfig.GitHub == nil {
+			gitConfig.GitHub = *inactive.GitHub
+		}
+		if inactive.GitLab != nil && src.Spec.GitConfig.GitLab == nil {
+			gitConfig.GitLab = *inactive.GitLab
+		}
+		if inactive.Bitbucket != nil && src.Spec.GitConfig.Bitbucket == nil {
+			gitConfig.Bitbucket = *inactive.Bitbucket
+		}
+		delete(dst.Annotations, inactiveGitProvidersAnnotation)
+		if len(dst.Annotations) == 0 {
+			dst.Annotations = nil
+		}
+	}
+
+	dst.Status = convertStatusFrom(src.Status)
+	return nil
+}
+
+// secretRefTo converts the name and key of a Secret into a reference, nil when both are empty
+func secretRefTo(name, key string) *v1beta1.SecretKeySelector {
+	if name == "" && key == "" {
+		return nil
+	}
+	return &v1beta1.SecretKeySelector{Name: name, Key: key}
+}
+
+// secretRefFrom converts a reference to a Secret into its name and key
+func secretRefFrom(ref *v1beta1.SecretKeySelector) (name, key string) {
+	if ref == nil {
+		return "", ""
+	}
+	return ref.Name, ref.Key
+}
+
+// convertGitConfigTo converts the block of the selected provider into the v1beta1
+// union, returning the non-empty blocks of the other providers next to it
+func convertGitConfigTo(src GitConfig) (v1beta1.GitConfig, inactiveGitProviders) {
+	dst := v1beta1.GitConfig{
+		Provider:           src.Provider,
+		DeploymentRepoName: src.DeployementRepoName,
+	}
+	if src.SSH != nil {
+		dst.SSH = &v1beta1.GitSSHConfig{
+			CloneURL:            src.SSH.CloneURL,
+			PrivateKeySecretRef: secretRefTo(src.SSH.PrivateKeySecretName, src.SSH.PrivateKeySecretKey),
+			KnownHostsSecretRef: secretRefTo(src.SSH.KnownHostsSecretName, src.SSH.KnownHostsSecretKey),
+		}
+	}
+	inactive := inactiveGitProviders{}
+
+	if src.Provider == "gitea" {
+		dst.Gitea = &v1beta1.GiteaProvider{
+			URL:                   src.Gitea.URL,
+			Username:              src.Gitea.Username,
+			Token:                 src.Gitea.Token,
+			TokenSecretRef:        secretRefTo(src.Gitea.TokenSecretName, src.Gitea.TokenSecretKey),
+			Password:              src.Gitea.Password,
+			PasswordSecretRef:     secretRefTo(src.Gitea.PasswordSecretName, src.Gitea.PasswordSecretKey),
+			CreateRepository:      src.Gitea.CreateRepository,
+			DefaultBranch:         src.Gitea.DefaultBranch,
+			TokenScopes:           append([]string(nil), src.Gitea.TokenScopes...),
+			TokenRotationInterval: src.Gitea.TokenRotationInterval.DeepCopy(),
+		}
+	} else if !reflect.DeepEqual(src.Gitea, GiteaProvider{}) {
+		inactive.Gitea = src.Gitea.DeepCopy()
+	}
+
+	if src.Provider == "github" {
+		dst.GitHub = &v1beta1.GitHubProvider{
+			APIURL:         src.GitHub.APIURL,
+			Owner:          src.GitHub.Owner,
+			Username:       src.GitHub.Username,
+			Token:          src.GitHub.Token,
+			TokenSecretRef: secretRefTo(src.GitHub.TokenSecretName, src.GitHub.TokenSecretKey),
+		}
+	} else if src.GitHub != (GitHubProvider{}) {
+		inactive.GitHub = src.GitHub.DeepCopy()
+	}
+
+	if src.Provider == "gitlab" {
+		dst.GitLab = &v1beta1.GitLabProvider{
+			URL:            src.GitLab.URL,
+			Namespace:      src.GitLab.Namespace,
+			Username:       src.GitLab.Username,
+			TokenType:      src.GitLab.TokenType,
+			Token:          src.GitLab.Token,
+			TokenSecretRef: secretRefTo(src.GitLab.TokenSecretName, src.GitLab.TokenSecretKey),
+			CASecretRef:    secretRefTo(src.GitLab.CASecretName, src.GitLab.CASecretKey),
+		}
+	} else if src.GitLab != (GitLabProvider{}) {
+		inactive.GitLab = src.GitLab.DeepCopy()
+	}
+
+	if src.Provider == "bitbucket" {
+		dst.Bitbucket = &v1beta1.BitbucketProvider{
+			URL:            src.Bitbucket.URL,
+			ProjectKey:     src.Bitbucket.ProjectKey,
+			RepoSlug:       src.Bitbucket.RepoSlug,
+			Username:       src.Bitbucket.Username,
+			Token:          src.Bitbucket.Token,
+			TokenSecretRef: secretRefTo(src.Bitbucket.TokenSecretName, src.Bitbucket.TokenSecretKey),
+		}
+	} else if src.Bitbucket != (BitbucketProvider{}) {
+		inactive.Bitbucket = src.Bitbucket.DeepCopy()
+	}
+	return dst, inactive
+}
+
+// convertGitConfigFrom converts the v1beta1 union into the blocks of the providers
+func convertGitConfigFrom(src v1beta1.GitConfig) GitConfig {
+	dst := GitConfig{
+		Provider:            src.Provider,
+		DeployementRepoName: src.DeploymentRepoName,
+	}
+	if ssh := src.SSH; ssh != nil {
+		dst.SSH = &GitSSHConfig{CloneURL: ssh.CloneURL}
+		dst.SSH.PrivateKeySecretName, dst.SSH.PrivateKeySecretKey = secretRefFrom(ssh.PrivateKeySecretRef)
+		dst.SSH.KnownHostsSecretName, dst.SSH.KnownHostsSecretKey = secretRefFrom(ssh.KnownHostsSecretRef)
+	}
+	if gitea := src.Gitea; gitea != nil {
+		dst.Gitea = GiteaProvider{
+			URL:                   gitea.URL,
+			Username:              gitea.Username,
+			Token:                 gitea.Token,
+			Password:              gitea.Password,
+			CreateRepository:      gitea.CreateRepository,
+			DefaultBranch:         gitea.DefaultBranch,
+			TokenScopes:           append([]string(nil), gitea.TokenScopes...),
+			TokenRotationInterval: gitea.TokenRotationInterval.DeepCopy(),
+		}
+		dst.Gitea.TokenSecretName, dst.Gitea.TokenSecretKey = secretRefFrom(gitea.TokenSecretRef)
+		dst.Gitea.PasswordSecretName, dst.Gitea.PasswordSecretKey = secretRefFrom(gitea.PasswordSecretRef)
+	}
+	if github := src.GitHub; github != nil {
+		dst.GitHub = GitHubProvider{
+			APIURL:   github.APIURL,
+			Owner:    github.Owner,
+			Username: github.Username,
+			Token:    github.Token,
+		}
+		dst.GitHub.TokenSecretName, dst.GitHub.TokenSecretKey = secretRefFrom(github.TokenSecretRef)
+	}
+	if gitlab := src.GitLab; gitlab != nil {
+		dst.GitLab = GitLabProvider{
+			URL:       gitlab.URL,
+			Namespace: gitlab.Namespace,
+			Username:  gitlab.Username,
+			TokenType: gitlab.TokenType,
+			Token:     gitlab.Token,
+		}
+		dst.GitLab.TokenSecretName, dst.GitLab.TokenSecretKey = secretRefFrom(gitlab.TokenSecretRef)
+		dst.GitLab.CASecretName, dst.GitLab.CASecretKey = secretRefFrom(gitlab.CASecretRef)
+	}
+	if bitbucket := src.Bitbucket; bitbucket != nil {
+		dst.Bitbucket = BitbucketProvider{
+			URL:        bitbucket.URL,
+			ProjectKey: bitbucket.ProjectKey,
+			RepoSlug:   bitbucket.RepoSlug,
+			Username:   bitbucket.Username,
+			Token:      bitbucket.Token,
+		}
+		dst.Bitbucket.TokenSecretName, dst.Bitbucket.TokenSecretKey = secretRefFrom(bitbucket.TokenSecretRef)
+	}
+	return dst
+}
+
+func convertArgoCDConfigTo(src ArgoCDConfig) v1beta1.ArgoCDConfig {
+	dst := v1beta1.ArgoCDConfig{
+		URL:               src.URL,
+		Username:          src.Username,
+		Password:          src.Password,
+		PasswordSecretRef: secretRefTo(src.PasswordSecretName, src.PasswordSecretKey),
+		TokenType:         src.TokenType,
+		Account:           src.Account,
+		TokenProject:      src.Project,
+		TokenRole:         src.Role,
+		TokenTTL:          src.TokenTTL.DeepCopy(),
+		Namespace:         src.Namespace,
+		Repository:        (*v1beta1.ArgoCDRepositoryConfig)(src.Repository.DeepCopy()),
+	}
+	if project := src.AppProject; project != nil {
+		dst.AppProject = &v1beta1.ArgoCDProjectConfig{
+			Name:                     project.Name,
+			ClusterResourceWhitelist: project.DeepCopy().ClusterResourceWhitelist,
+		}
+		for _, destination := range project.Destinations {
+			dst.AppProject.Destinations = append(dst.AppProject.Destinations, v1beta1.ArgoCDProjectDestination(destination))
+		}
+	}
+	return dst
+}
+
+func convertArgoCDConfigFrom(src v1beta1.ArgoCDConfig) ArgoCDConfig {
+	dst := ArgoCDConfig{
+		URL:        src.URL,
+		Username:   src.Username,
+		Password:   src.Password,
+		TokenType:  src.TokenType,
+		Account:    src.Account,
+		Project:    src.TokenProject,
+		Role:       src.TokenRole,
+		TokenTTL:   src.TokenTTL.DeepCopy(),
+		Namespace:  src.Namespace,
+		Repository: (*ArgoCDRepositoryConfig)(src.Repository.DeepCopy()),
+	}
+	dst.PasswordSecretName, dst.PasswordSecretKey = secretRefFrom(src.PasswordSecretRef)
+	if project := src.AppProject; project != nil {
+		dst.AppProject = &ArgoCDProjectConfig{
+			Name:                     project.Name,
+			ClusterResourceWhitelist: project.DeepCopy().ClusterResourceWhitelist,
+		}
+		for _, destination := range project.Destinations {
+			dst.AppProject.Destinations = append(dst.AppProject.Destinations, ArgoCDProjectDestination(destination))
+		}
+	}
+	return dst
+}
+
+func convertStatusTo(src GenezioManagerStatus) v1beta1.GenezioManagerStatus {
+	copied := src.DeepCopy()
+	dst := v1beta1.GenezioManagerStatus{
+		Conditions:                copied.Conditions,
+		ObservedGeneration:        src.ObservedGeneration,
+		Replicas:                  src.Replicas,
+		ReadyReplicas:             src.ReadyReplicas,
+		Ready:                     src.Ready,
+		UpdatedReplicas:           src.UpdatedReplicas,
+		AvailableReplicas:         src.AvailableReplicas,
+		Image:                     src.Image,
+		ImageDigest:               src.ImageDigest,
+		SecretHash:                src.SecretHash,
+		BaseURL:                   src.BaseURL,
+		DeploymentRepository:      (*v1beta1.DeploymentRepositoryStatus)(copied.DeploymentRepository),
+		GiteaTokenRotationTime:    copied.GiteaTokenRotationTime,
+		ArgoCDTokenExpirationTime: copied.ArgoCDTokenExpirationTime,
+		DependenciesCheckTime:     copied.DependenciesCheckTime,
+	}
+	for _, hostKey := range src.SSHHostKeys {
+		dst.SSHHostKeys = append(dst.SSHHostKeys, v1beta1.SSHHostKey(hostKey))
+	}
+	return dst
+}
+
+func convertStatusFrom(src v1beta1.GenezioManagerStatus) GenezioManagerStatus {
+	copied := src.DeepCopy()
+	dst := GenezioManagerStatus{
+		Conditions:                copied.Conditions,
+		ObservedGeneration:        src.ObservedGeneration,
+		Replicas:                  src.Replicas,
+		ReadyReplicas:             src.ReadyReplicas,
+		Ready:                     src.Ready,
+		UpdatedReplicas:           src.UpdatedReplicas,
+		AvailableReplicas:         src.AvailableReplicas,
+		Image:                     src.Image,
+		ImageDigest:               src.ImageDigest,
+		SecretHash:                src.SecretHash,
+		BaseURL:                   src.BaseURL,
+		DeploymentRepository:      (*DeploymentRepositoryStatus)(copied.DeploymentRepository),
+		GiteaTokenRotationTime:    copied.GiteaTokenRotationTime,
+		ArgoCDTokenExpirationTime: copied.ArgoCDTokenExpirationTime,
+		DependenciesCheckTime:     copied.DependenciesCheckTime,
+	}
+	for _, hostKey := range src.SSHHostKeys {
+		dst.SSHHostKeys = append(dst.SSHHostKeys, SSHHostKey(hostKey))
+	}
+	return dst
+}
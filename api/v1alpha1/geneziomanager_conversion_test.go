@@ -0,0 +1,186 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Genez-io/genezio-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fullGenezioManager sets every field which has a v1beta1 counterpart, with the
+// blocks of inactive git providers filled in
+func fullGenezioManager() *GenezioManager {
+	interval := &metav1.Duration{Duration: time.Hour}
+	rotated := metav1.NewTime(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	return &GenezioManager{
+		ObjectMeta: metav1.ObjectMeta{Name: "manager", Namespace: "default",
+			Annotations: map[string]string{"example.com/owner": "platform"}},
+		Spec: GenezioManagerSpec{
+			ArgoCDConfig: ArgoCDConfig{
+				URL:                "https://argocd.example.com",
+				Username:           "admin",
+				PasswordSecretName: "argocd",
+				PasswordSecretKey:  "password",
+				TokenType:          "project",
+				Project:            "genezio",
+				Role:               "deployer",
+				TokenTTL:           interval,
+				Namespace:          "argocd",
+				Repository:         &ArgoCDRepositoryConfig{SecretType: "repo-creds"},
+				AppProject: &ArgoCDProjectConfig{
+					Name:                     "genezio",
+					Destinations:             []ArgoCDProjectDestination{{Namespace: "apps-*"}},
+					ClusterResourceWhitelist: []metav1.GroupKind{{Group: "", Kind: "Namespace"}},
+				},
+			},
+			GitConfig: GitConfig{
+				Provider:            "gitlab",
+				DeployementRepoName: "deployments",
+				Gitea: GiteaProvider{
+					URL:                   "https://gitea.example.com",
+					Username:              "genezio",
+					PasswordSecretName:    "gitea",
+					PasswordSecretKey:     "password",
+					TokenScopes:           []string{"write:repository"},
+					TokenRotationInterval: interval,
+				},
+				GitHub: GitHubProvider{Owner: "genezio", Token: "github-token"},
+				GitLab: GitLabProvider{
+					URL:             "https://gitlab.example.com",
+					Namespace:       "platform/deployments",
+					Username:        "genezio",
+					TokenSecretName: "gitlab",
+					TokenSecretKey:  "token",
+					CASecretName:    "gitlab-ca",
+					CASecretKey:     "ca.crt",
+				},
+				SSH: &GitSSHConfig{
+					CloneURL:             "ssh://git@gitlab.example.com/platform/deployments.git",
+					PrivateKeySecretName: "ssh",
+					PrivateKeySecretKey:  "id_ed25519",
+					KnownHostsSecretName: "ssh",
+				},
+			},
+			ContainerRegistryConfig: ContainerRegistryConfig{
+				URL:                "registry.example.com",
+				Username:           "genezio",
+				PasswordSecretName: "registry",
+				PasswordSecretKey:  "password",
+				PullSecretReplication: &PullSecretReplication{
+					Namespaces: []string{"apps"},
+				},
+			},
+			Region:        "eu-central-1",
+			ContainerPort: 8080,
+			ChartRepo:     "https://charts.example.com",
+			ChartRev:      "v1",
+			Service:       ServiceConfig{Type: corev1.ServiceTypeNodePort, Port: 80},
+			Ingress:       &IngressConfig{Host: "manager.example.com"},
+			CD:            CDConfig{Engine: "argocd"},
+			Domain:        "apps.example.com",
+			Image: &ImageConfig{Repository: "mirror.example.com/genezio-manager", Tag: "v1",
+				PullPolicy: corev1.PullIfNotPresent, ResolveDigest: true},
+		},
+		Status: GenezioManagerStatus{
+			Conditions: []metav1.Condition{{Type: "Available", Status: metav1.ConditionTrue,
+				Reason: "Reconciled", LastTransitionTime: rotated}},
+			ObservedGeneration:     2,
+			Replicas:               1,
+			ReadyReplicas:          1,
+			Ready:                  "1/1",
+			Image:                  "genezio/genezio-manager:v1",
+			ImageDigest:            "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			DeploymentRepository:   &DeploymentRepositoryStatus{CloneURL: "https://gitlab.example.com/deployments.git"},
+			GiteaTokenRotationTime: &rotated,
+			DependenciesCheckTime:  &rotated,
+			SSHHostKeys:            []SSHHostKey{{Hosts: "gitlab.example.com", Type: "ssh-ed25519", Fingerprint: "SHA256:abc"}},
+		},
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	src := fullGenezioManager()
+	hub := &v1beta1.GenezioManager{}
+	if err := src.DeepCopy().ConvertTo(hub); err != nil {
+		t.Fatalf("unexpected error converting to v1beta1: %v", err)
+	}
+
+	if hub.Spec.GitConfig.DeploymentRepoName != "deployments" || hub.Spec.GitConfig.GitLab == nil ||
+		hub.Spec.GitConfig.Gitea != nil || hub.Spec.GitConfig.GitHub != nil {
+		t.Errorf("expected only the gitlab block in the v1beta1 union, got %+v", hub.Spec.GitConfig)
+	}
+	if ref := hub.Spec.GitConfig.GitLab.TokenSecretRef; ref == nil || *ref != (v1beta1.SecretKeySelector{
+		Name: "gitlab", Key: "token"}) {
+		t.Errorf("unexpected token reference %+v", ref)
+	}
+	if ssh := hub.Spec.GitConfig.SSH; ssh == nil ||
+		*ssh.PrivateKeySecretRef != (v1beta1.SecretKeySelector{Name: "ssh", Key: "id_ed25519"}) ||
+		*ssh.KnownHostsSecretRef != (v1beta1.SecretKeySelector{Name: "ssh"}) {
+		t.Errorf("unexpected SSH secret references %+v", ssh)
+	}
+	if _, ok := hub.Annotations[inactiveGitProvidersAnnotation]; !ok {
+		t.Errorf("expected the inactive git providers to be kept in an annotation")
+	}
+
+	dst := &GenezioManager{}
+	if err := dst.ConvertFrom(hub); err != nil {
+		t.Fatalf("unexpected error converting from v1beta1: %v", err)
+	}
+	if !reflect.DeepEqual(dst, src) {
+		t.Errorf("the round trip is lossy:\nwant %+v\ngot  %+v", src, dst)
+	}
+}
+
+func TestConvertFromHubRoundTrip(t *testing.T) {
+	hub := &v1beta1.GenezioManager{
+		ObjectMeta: metav1.ObjectMeta{Name: "manager", Namespace: "default"},
+		Spec: v1beta1.GenezioManagerSpec{
+			GitConfig: v1beta1.GitConfig{
+				Provider:           "github",
+				DeploymentRepoName: "deployments",
+				GitHub: &v1beta1.GitHubProvider{
+					Owner:          "genezio",
+					TokenSecretRef: &v1beta1.SecretKeySelector{Name: "github", Key: "token"},
+				},
+			},
+			ContainerRegistryConfig: v1beta1.ContainerRegistryConfig{URL: "ghcr.io", Username: "genezio",
+				Password: "registry-password"},
+			Region: "eu-central-1",
+		},
+	}
+
+	spoke := &GenezioManager{}
+	if err := spoke.ConvertFrom(hub.DeepCopy()); err != nil {
+		t.Fatalf("unexpected error converting from v1beta1: %v", err)
+	}
+	if spoke.Spec.GitConfig.GitHub.TokenSecretName != "github" || spoke.Spec.GitConfig.GitHub.TokenSecretKey != "token" {
+		t.Errorf("unexpected token reference %+v", spoke.Spec.GitConfig.GitHub)
+	}
+
+	dst := &v1beta1.GenezioManager{}
+	if err := spoke.ConvertTo(dst); err != nil {
+		t.Fatalf("unexpected error converting to v1beta1: %v", err)
+	}
+	if !reflect.DeepEqual(dst, hub) {
+		t.Errorf("the round trip is lossy:\nwant %+v\ngot  %+v", hub, dst)
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -25,7 +26,13 @@ import (
 type GitConfigGenezio struct {
 }
 
+// GiteaProvider configures a deployment repository hosted on Gitea
+// +kubebuilder:validation:XValidation:rule="!(has(self.token) && has(self.tokenSecretName))",message="token and tokenSecretName are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="has(self.tokenSecretName) == has(self.tokenSecretKey)",message="tokenSecretName and tokenSecretKey must be set together"
+// +kubebuilder:validation:XValidation:rule="!(has(self.password) && has(self.passwordSecretName))",message="password and passwordSecretName are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="has(self.passwordSecretName) == has(self.passwordSecretKey)",message="passwordSecretName and passwordSecretKey must be set together"
 type GiteaProvider struct {
+	// +kubebuilder:validation:MaxLength=2048
 	URL                string `json:"url"`
 	Username           string `json:"username"`
 	Token              string `json:"token,omitempty"`
@@ -34,29 +41,316 @@ type GiteaProvider struct {
 	Password           string `json:"password,omitempty"`
 	PasswordSecretKey  string `json:"passwordSecretKey,omitempty"`
 	PasswordSecretName string `json:"passwordSecretName,omitempty"`
+	// CreateRepository makes the operator create the deployment repository when it
+	// does not exist. Otherwise the repository is only verified
+	// +optional
+	CreateRepository bool `json:"createRepository,omitempty"`
+	// DefaultBranch of the created deployment repository, defaults to main
+	// +optional
+	DefaultBranch string `json:"defaultBranch,omitempty"`
+	// TokenScopes of the access token minted from the password when no token is
+	// given, defaults to write:repository and read:user
+	// +optional
+	TokenScopes []string `json:"tokenScopes,omitempty"`
+	// TokenRotationInterval is the age after which the minted access token is
+	// replaced. The token is not rotated when unset
+	// +optional
+	TokenRotationInterval *metav1.Duration `json:"tokenRotationInterval,omitempty"`
 }
 
+// GitHubProvider configures a deployment repository hosted on GitHub or GitHub Enterprise
+// +kubebuilder:validation:XValidation:rule="!(has(self.token) && has(self.tokenSecretName))",message="token and tokenSecretName are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="has(self.tokenSecretName) == has(self.tokenSecretKey)",message="tokenSecretName and tokenSecretKey must be set together"
+type GitHubProvider struct {
+	// APIURL is the base URL of the GitHub API, e.g. https://github.example.com/api/v3
+	// for GitHub Enterprise. Defaults to https://api.github.com
+	// +kubebuilder:validation:Pattern=`^https?://.+`
+	// +optional
+	APIURL string `json:"apiUrl,omitempty"`
+	// Owner is the user or organization owning the deployment repository
+	Owner string `json:"owner"`
+	// +optional
+	Username        string `json:"username,omitempty"`
+	Token           string `json:"token,omitempty"`
+	TokenSecretKey  string `json:"tokenSecretKey,omitempty"`
+	TokenSecretName string `json:"tokenSecretName,omitempty"`
+}
+
+// GitLabProvider configures a deployment repository hosted on a self-managed GitLab
+// +kubebuilder:validation:XValidation:rule="!(has(self.token) && has(self.tokenSecretName))",message="token and tokenSecretName are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="has(self.tokenSecretName) == has(self.tokenSecretKey)",message="tokenSecretName and tokenSecretKey must be set together"
+// +kubebuilder:validation:XValidation:rule="has(self.caSecretName) == has(self.caSecretKey)",message="caSecretName and caSecretKey must be set together"
+type GitLabProvider struct {
+	// +kubebuilder:validation:MaxLength=2048
+	URL string `json:"url"`
+	// Namespace is the group owning the deployment repository, including its
+	// subgroups (e.g. platform/deployments). Defaults to the namespace of the token owner
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Username used for HTTPS authentication. Required for personal access tokens,
+	// project and group access tokens accept any non-empty value
+	// +optional
+	Username string `json:"username,omitempty"`
+	// TokenType is the kind of access token, defaults to personal
+	// +kubebuilder:validation:Enum=personal;project;group
+	// +optional
+	TokenType       string `json:"tokenType,omitempty"`
+	Token           string `json:"token,omitempty"`
+	TokenSecretKey  string `json:"tokenSecretKey,omitempty"`
+	TokenSecretName string `json:"tokenSecretName,omitempty"`
+	// CASecretName is the name of a Secret holding the CA bundle used to verify
+	// the certificate of the GitLab instance
+	// +optional
+	CASecretName string `json:"caSecretName,omitempty"`
+	// +optional
+	CASecretKey string `json:"caSecretKey,omitempty"`
+}
+
+// BitbucketProvider configures a deployment repository hosted on Bitbucket Server or Data Center
+// +kubebuilder:validation:XValidation:rule="!(has(self.token) && has(self.tokenSecretName))",message="token and tokenSecretName are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="has(self.tokenSecretName) == has(self.tokenSecretKey)",message="tokenSecretName and tokenSecretKey must be set together"
+type BitbucketProvider struct {
+	// +kubebuilder:validation:MaxLength=2048
+	URL string `json:"url"`
+	// ProjectKey is the key of the project holding the deployment repository
+	ProjectKey string `json:"projectKey"`
+	// RepoSlug is the slug of the deployment repository, defaults to deployementRepoName
+	// +optional
+	RepoSlug string `json:"repoSlug,omitempty"`
+	Username string `json:"username"`
+	// Token is an HTTP access token of the user, project or repository
+	Token           string `json:"token,omitempty"`
+	TokenSecretKey  string `json:"tokenSecretKey,omitempty"`
+	TokenSecretName string `json:"tokenSecretName,omitempty"`
+}
+
+// ContainerRegistryConfig configures the registry the images of the applications are pushed to
+// +kubebuilder:validation:XValidation:rule="!(has(self.password) && has(self.passwordSecretName))",message="password and passwordSecretName are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="has(self.passwordSecretName) == has(self.passwordSecretKey)",message="passwordSecretName and passwordSecretKey must be set together"
 type ContainerRegistryConfig struct {
 	URL                string `json:"url"`
 	Username           string `json:"username"`
 	Password           string `json:"password,omitempty"`
 	PasswordSecretKey  string `json:"passwordSecretKey,omitempty"`
 	PasswordSecretName string `json:"passwordSecretName,omitempty"`
+	// PullSecretReplication copies the image pull Secret generated from the
+	// registry credentials to the namespaces the applications are deployed to
+	// +optional
+	PullSecretReplication *PullSecretReplication `json:"pullSecretReplication,omitempty"`
+}
+
+// PullSecretReplication selects the namespaces the image pull Secret is replicated to
+type PullSecretReplication struct {
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceSelector selects namespaces in addition to Namespaces
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
 }
 
+// GitSSHConfig configures SSH authentication against the deployment repository.
+// The private key and known_hosts are mounted read-only into the genezio-manager.
+type GitSSHConfig struct {
+	// CloneURL is the SSH URL of the deployment repository, e.g. ssh://git@gitea.example.com:2222/genezio/deployments.git
+	CloneURL             string `json:"cloneUrl"`
+	PrivateKeySecretName string `json:"privateKeySecretName"`
+	// +optional
+	PrivateKeySecretKey  string `json:"privateKeySecretKey,omitempty"`
+	KnownHostsSecretName string `json:"knownHostsSecretName"`
+	// +optional
+	KnownHostsSecretKey string `json:"knownHostsSecretKey,omitempty"`
+}
+
+// GitConfig configures the deployment repository. Only the block of the selected
+// provider is used, the blocks of the other providers are ignored.
+// +kubebuilder:validation:XValidation:rule="self.provider != 'gitea' || (has(self.gitea) && self.gitea.url.matches('^https?://.+') && size(self.gitea.username) > 0)",message="gitea.url must be an http(s) URL and gitea.username is required with the gitea provider"
+// +kubebuilder:validation:XValidation:rule="self.provider != 'github' || (has(self.github) && size(self.github.owner) > 0)",message="github.owner is required with the github provider"
+// +kubebuilder:validation:XValidation:rule="self.provider != 'github' || (has(self.github) && has(self.github.token) != has(self.github.tokenSecretName))",message="exactly one of github.token or github.tokenSecretName is required with the github provider"
+// +kubebuilder:validation:XValidation:rule="self.provider != 'gitlab' || (has(self.gitlab) && self.gitlab.url.matches('^https?://.+'))",message="gitlab.url must be an http(s) URL with the gitlab provider"
+// +kubebuilder:validation:XValidation:rule="self.provider != 'gitlab' || (has(self.gitlab) && has(self.gitlab.token) != has(self.gitlab.tokenSecretName))",message="exactly one of gitlab.token or gitlab.tokenSecretName is required with the gitlab provider"
+// +kubebuilder:validation:XValidation:rule="self.provider != 'gitlab' || !has(self.gitlab) || (has(self.gitlab.tokenType) && self.gitlab.tokenType != 'personal') || has(self.gitlab.username)",message="gitlab.username is required for personal access tokens"
+// +kubebuilder:validation:XValidation:rule="self.provider != 'bitbucket' || (has(self.bitbucket) && self.bitbucket.url.matches('^https?://.+') && size(self.bitbucket.projectKey) > 0 && size(self.bitbucket.username) > 0)",message="bitbucket.url must be an http(s) URL and bitbucket.projectKey and bitbucket.username are required with the bitbucket provider"
+// +kubebuilder:validation:XValidation:rule="self.provider != 'bitbucket' || (has(self.bitbucket) && has(self.bitbucket.token) != has(self.bitbucket.tokenSecretName))",message="exactly one of bitbucket.token or bitbucket.tokenSecretName is required with the bitbucket provider"
 type GitConfig struct {
-	Provider            string        `json:"provider"`
-	DeployementRepoName string        `json:"deployementRepoName"`
-	Gitea               GiteaProvider `json:"gitea,omitempty"`
-	// More such as github, gitlab, bitbucket will be added here
+	// Provider hosting the deployment repository, defaults to gitea
+	// +kubebuilder:validation:Enum=gitea;github;gitlab;bitbucket
+	// +kubebuilder:default=gitea
+	// +optional
+	Provider string `json:"provider,omitempty"`
+	// +kubebuilder:validation:MinLength=1
+	DeployementRepoName string            `json:"deployementRepoName"`
+	Gitea               GiteaProvider     `json:"gitea,omitempty"`
+	GitHub              GitHubProvider    `json:"github,omitempty"`
+	GitLab              GitLabProvider    `json:"gitlab,omitempty"`
+	Bitbucket           BitbucketProvider `json:"bitbucket,omitempty"`
+	// SSH switches the genezio-manager to SSH authentication for git operations
+	// +optional
+	SSH *GitSSHConfig `json:"ssh,omitempty"`
+	// More providers will be added here
 }
 
+// ArgoCDConfig configures the argocd engine
+// +kubebuilder:validation:XValidation:rule="!(has(self.password) && has(self.passwordSecretName))",message="password and passwordSecretName are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="has(self.passwordSecretName) == has(self.passwordSecretKey)",message="passwordSecretName and passwordSecretKey must be set together"
+// +kubebuilder:validation:XValidation:rule="!has(self.username) || has(self.password) || has(self.passwordSecretName)",message="password or passwordSecretName is required with a username"
+// +kubebuilder:validation:XValidation:rule="!has(self.tokenType) || self.tokenType != 'project' || has(self.role)",message="role is required for project tokens"
 type ArgoCDConfig struct {
-	URL                string `json:"url,omitempty"`
+	// +kubebuilder:validation:Pattern=`^https?://.+`
+	URL string `json:"url,omitempty"`
+	// Username makes the operator log into ArgoCD with the password and hand a
+	// generated token to the genezio-manager. Without it the password is passed
+	// through as the token
 	Username           string `json:"username,omitempty"`
 	Password           string `json:"password,omitempty"`
 	PasswordSecretKey  string `json:"passwordSecretKey,omitempty"`
 	PasswordSecretName string `json:"passwordSecretName,omitempty"`
+	// TokenType is the kind of token generated after logging in, defaults to session
+	// +kubebuilder:validation:Enum=session;account;project
+	// +optional
+	TokenType string `json:"tokenType,omitempty"`
+	// Account the account token is generated for, defaults to Username
+	// +optional
+	Account string `json:"account,omitempty"`
+	// Project and Role the project token is generated for. Project defaults to the
+	// AppProject managed through AppProject, which then gets the role
+	// +optional
+	Project string `json:"project,omitempty"`
+	// +optional
+	Role string `json:"role,omitempty"`
+	// TokenTTL is the lifetime of generated account and project tokens, defaults to 24h
+	// +optional
+	TokenTTL *metav1.Duration `json:"tokenTTL,omitempty"`
+	// Namespace ArgoCD is installed in, defaults to argocd
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Repository registers the credentials of the deployment repository in ArgoCD
+	// +optional
+	Repository *ArgoCDRepositoryConfig `json:"repository,omitempty"`
+	// AppProject makes the operator manage an AppProject restricting what the
+	// genezio-manager can deploy
+	// +optional
+	AppProject *ArgoCDProjectConfig `json:"appProject,omitempty"`
+}
+
+// ArgoCDRepositoryConfig configures the Secret declaring the deployment repository to ArgoCD
+type ArgoCDRepositoryConfig struct {
+	// SecretType is repository to declare the deployment repository itself, or
+	// repo-creds to declare a credential template matching every repository of
+	// its owner. Defaults to repository
+	// +kubebuilder:validation:Enum=repository;repo-creds
+	// +optional
+	SecretType string `json:"secretType,omitempty"`
+}
+
+// ArgoCDProjectDestination is a cluster and namespace applications may be deployed to
+type ArgoCDProjectDestination struct {
+	// Server is the API server URL of the cluster, defaults to the cluster ArgoCD runs in
+	// +optional
+	Server string `json:"server,omitempty"`
+	// Namespace may be a glob pattern, e.g. tenant-a-*
+	Namespace string `json:"namespace"`
+}
+
+// ArgoCDProjectConfig configures the AppProject of the GenezioManager
+type ArgoCDProjectConfig struct {
+	// Name of the AppProject, defaults to genezio-<namespace>-<name>
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Destinations the applications may be deployed to, defaults to the namespace
+	// of the GenezioManager
+	// +optional
+	Destinations []ArgoCDProjectDestination `json:"destinations,omitempty"`
+	// ClusterResourceWhitelist lists the cluster-scoped kinds the applications may
+	// create. None are allowed when empty
+	// +optional
+	ClusterResourceWhitelist []metav1.GroupKind `json:"clusterResourceWhitelist,omitempty"`
+}
+
+// CDConfig selects the continuous delivery engine of the genezio-manager
+// +kubebuilder:validation:XValidation:rule="has(self.flux) == (has(self.engine) && self.engine == 'flux')",message="flux must be set if and only if the engine is flux"
+type CDConfig struct {
+	// Engine deploying the applications from the deployment repository, defaults to argocd
+	// +kubebuilder:validation:Enum=argocd;flux
+	// +optional
+	Engine string `json:"engine,omitempty"`
+	// Flux configures the flux engine, it must be set if and only if the engine is flux
+	// +optional
+	Flux *FluxConfig `json:"flux,omitempty"`
+}
+
+// FluxConfig configures the Flux objects applying the deployment repository. They
+// are created in the namespace of the GenezioManager.
+type FluxConfig struct {
+	// Kind of the Flux object applying the deployment repository, defaults to Kustomization
+	// +kubebuilder:validation:Enum=Kustomization;HelmRelease
+	// +optional
+	Kind string `json:"kind,omitempty"`
+	// Path of the kustomization or of the chart in the deployment repository, defaults to ./apps
+	// +optional
+	Path string `json:"path,omitempty"`
+	// Interval at which Flux reconciles the deployment repository, defaults to 1m
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// TargetNamespace overrides the namespace of the applications
+	// +optional
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+	// Prune removes the applications deleted from the deployment repository
+	// +optional
+	Prune bool `json:"prune,omitempty"`
+}
+
+// ImageConfig overrides parts of the genezio-manager image set on the operator
+type ImageConfig struct {
+	// Repository of the image including its registry, e.g. ghcr.io/genez-io/genezio-manager.
+	// Defaults to the repository of the image set on the operator
+	// +kubebuilder:validation:MaxLength=255
+	// +optional
+	Repository string `json:"repository,omitempty"`
+	// Tag of the image, defaults to the tag of the image set on the operator
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127}$`
+	// +optional
+	Tag string `json:"tag,omitempty"`
+	// Digest pins the image, e.g. sha256:<hex>. The kubelet pulls the digest even
+	// when a tag is set
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]+([+._-][a-z0-9]+)*:[a-zA-Z0-9=_-]{32,}$`
+	// +optional
+	Digest string `json:"digest,omitempty"`
+	// PullPolicy of the genezio-manager container, defaults to Always
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	// +optional
+	PullPolicy corev1.PullPolicy `json:"pullPolicy,omitempty"`
+	// ResolveDigest makes the operator resolve the tag to a digest against the registry
+	// and pin the Deployment to it. The tag is resolved again only when the image changes
+	// +optional
+	ResolveDigest bool `json:"resolveDigest,omitempty"`
+}
+
+// ServiceConfig configures the Service exposing the genezio-manager container
+type ServiceConfig struct {
+	// Type of the Service, defaults to ClusterIP
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +optional
+	Type corev1.ServiceType `json:"type,omitempty"`
+	// Port exposed by the Service, defaults to the container port
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+	// Annotations added to the Service, e.g. to configure a cloud load balancer
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// IngressConfig configures the Ingress routing external traffic to the Service
+type IngressConfig struct {
+	Host string `json:"host"`
+	// TLSSecretName is the name of the Secret holding the certificate for Host
+	// +optional
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+	// +optional
+	IngressClassName string `json:"ingressClassName,omitempty"`
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 // GenezioManagerSpec defines the desired state of GenezioManager
@@ -64,13 +358,63 @@ type GenezioManagerSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	ArgoCDConfig            ArgoCDConfig            `json:"argocdConfig"`
+	// ArgoCDConfig configures the argocd engine, it must be left empty with the flux engine
+	// +optional
+	ArgoCDConfig            ArgoCDConfig            `json:"argocdConfig,omitempty"`
 	GitConfig               GitConfig               `json:"gitConfig"`
 	ContainerRegistryConfig ContainerRegistryConfig `json:"containerRegistryConfig"`
-	Region                  string                  `json:"region"`
-	ContainerPort           int32                   `json:"containerPort"`
-	ChartRepo               string                  `json:"chartRepo"`
-	ChartRev                string                  `json:"chartRev"`
+	// Region cannot be changed once the GenezioManager is created
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="region is immutable"
+	Region string `json:"region"`
+	// ContainerPort is the port the genezio-manager listens on, defaults to 8080
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=8080
+	// +optional
+	ContainerPort int32  `json:"containerPort,omitempty"`
+	ChartRepo     string `json:"chartRepo"`
+	// ChartRev is the revision of the charts repository, defaults to HEAD
+	// +kubebuilder:default=HEAD
+	// +optional
+	ChartRev string `json:"chartRev,omitempty"`
+	// +optional
+	Service ServiceConfig `json:"service,omitempty"`
+	// Ingress is created only when set
+	// +optional
+	Ingress *IngressConfig `json:"ingress,omitempty"`
+	// CD selects the engine deploying the applications from the deployment repository
+	// +optional
+	CD CDConfig `json:"cd,omitempty"`
+	// Image overrides the genezio-manager image set on the operator for this GenezioManager
+	// +optional
+	Image *ImageConfig `json:"image,omitempty"`
+	// Domain is the DNS subdomain the applications are published under, defaults to local
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +kubebuilder:default=local
+	// +optional
+	Domain string `json:"domain,omitempty"`
+	// WildcardTLSSecretName is the name of the Secret holding a certificate for *.<domain>,
+	// used by the ingresses of the applications
+	// +optional
+	WildcardTLSSecretName string `json:"wildcardTLSSecretName,omitempty"`
+	// AppsIngressClassName is the class of the ingresses of the applications
+	// +optional
+	AppsIngressClassName string `json:"appsIngressClassName,omitempty"`
+}
+
+// SSHHostKey is a host key trusted through the known_hosts of the SSH configuration
+type SSHHostKey struct {
+	Hosts string `json:"hosts"`
+	Type  string `json:"type"`
+	// Fingerprint is the SHA256 fingerprint of the key, as printed by ssh-keygen -l
+	Fingerprint string `json:"fingerprint"`
+}
+
+// DeploymentRepositoryStatus describes the deployment repository found on the git provider
+type DeploymentRepositoryStatus struct {
+	CloneURL      string `json:"cloneUrl,omitempty"`
+	DefaultBranch string `json:"defaultBranch,omitempty"`
 }
 
 // GenezioManagerStatus defines the observed state of GenezioManager
@@ -81,10 +425,80 @@ type GenezioManagerStatus struct {
 	// Conditions store the status conditions of the Memcached instances
 	// +operator-sdk:csv:customresourcedefinitions:type=status
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// ObservedGeneration is the most recent generation of the spec reconciled by the operator
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Replicas is the number of genezio-manager pods targeted by the Deployment
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// ReadyReplicas is the number of genezio-manager pods with a Ready condition
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// Ready is the number of ready genezio-manager pods out of the desired ones, e.g. 1/1
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Ready string `json:"ready,omitempty"`
+
+	// UpdatedReplicas is the number of genezio-manager pods running the desired template
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	UpdatedReplicas int32 `json:"updatedReplicas,omitempty"`
+
+	// AvailableReplicas is the number of genezio-manager pods available to serve requests
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+
+	// Image is the genezio-manager image currently rolled out
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Image string `json:"image,omitempty"`
+
+	// ImageDigest is the digest the genezio-manager image is pinned to, either given in
+	// the spec or resolved from its tag
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	ImageDigest string `json:"imageDigest,omitempty"`
+
+	// SecretHash is the hash of the Secrets used by the genezio-manager stamped on the
+	// pods of the Deployment, a change of one of them rolls the pods
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	SecretHash string `json:"secretHash,omitempty"`
+
+	// BaseURL is the URL the applications are published under, as subdomains of its host
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	BaseURL string `json:"baseURL,omitempty"`
+
+	// DeploymentRepository is the deployment repository verified or created by the operator
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	DeploymentRepository *DeploymentRepositoryStatus `json:"deploymentRepository,omitempty"`
+
+	// GiteaTokenRotationTime is when the Gitea access token used by the genezio-manager was last minted
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	GiteaTokenRotationTime *metav1.Time `json:"giteaTokenRotationTime,omitempty"`
+
+	// ArgoCDTokenExpirationTime is when the ArgoCD token used by the genezio-manager expires
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	ArgoCDTokenExpirationTime *metav1.Time `json:"argocdTokenExpirationTime,omitempty"`
+
+	// DependenciesCheckTime is when the git provider, ArgoCD and the container registry
+	// were last checked
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	DependenciesCheckTime *metav1.Time `json:"dependenciesCheckTime,omitempty"`
+
+	// SSHHostKeys are the host keys trusted for SSH access to the deployment repository
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	SSHHostKeys []SSHHostKey `json:"sshHostKeys,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=gm,categories=genezio
+//+kubebuilder:printcolumn:name="Region",type=string,JSONPath=`.spec.region`
+//+kubebuilder:printcolumn:name="Provider",type=string,JSONPath=`.spec.gitConfig.provider`
+//+kubebuilder:printcolumn:name="Chart Rev",type=string,JSONPath=`.spec.chartRev`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.ready`
+//+kubebuilder:printcolumn:name="Available",type=string,JSONPath=`.status.conditions[?(@.type=="Available")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // GenezioManager is the Schema for the geneziomanagers API
 // +kubebuilder:subresource:status
@@ -0,0 +1,317 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// The checks below are shared by the validating webhook and the controller, which
+// runs them again for objects stored before a check was added. They report every
+// problem with the path of the offending field.
+
+// ValidateGitConfig checks that the block of the selected provider is filled in
+func ValidateGitConfig(gitConfig GitConfig, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if gitConfig.DeployementRepoName == "" {
+		errs = append(errs, field.Required(path.Child("deployementRepoName"), ""))
+	}
+
+	switch gitConfig.Provider {
+	case "gitea":
+		gitea := path.Child("gitea")
+		errs = append(errs, validateHTTPURL(gitConfig.Gitea.URL, gitea.Child("url"))...)
+		if gitConfig.Gitea.Username == "" {
+			errs = append(errs, field.Required(gitea.Child("username"), ""))
+		}
+	case "github":
+		github := path.Child("github")
+		if gitConfig.GitHub.Owner == "" {
+			errs = append(errs, field.Required(github.Child("owner"), ""))
+		}
+		if gitConfig.GitHub.APIURL != "" {
+			errs = append(errs, validateHTTPURL(gitConfig.GitHub.APIURL, github.Child("apiUrl"))...)
+		}
+		errs = append(errs, requireCredential(github, "token", gitConfig.GitHub.Token,
+			gitConfig.GitHub.TokenSecretName)...)
+	case "gitlab":
+		gitlab := path.Child("gitlab")
+		errs = append(errs, validateHTTPURL(gitConfig.GitLab.URL, gitlab.Child("url"))...)
+		if namespace := gitConfig.GitLab.Namespace; namespace != "" {
+			for _, segment := range strings.Split(namespace, "/") {
+				if segment == "" {
+					errs = append(errs, field.Invalid(gitlab.Child("namespace"), namespace,
+						"must be a group path such as group/subgroup"))
+					break
+				}
+			}
+		}
+		if (gitConfig.GitLab.TokenType == "" || gitConfig.GitLab.TokenType == "personal") &&
+			gitConfig.GitLab.Username == "" {
+			errs = append(errs, field.Required(gitlab.Child("username"), "required for personal access tokens"))
+		}
+		errs = append(errs, requireCredential(gitlab, "token", gitConfig.GitLab.Token,
+			gitConfig.GitLab.TokenSecretName)...)
+	case "bitbucket":
+		bitbucket := path.Child("bitbucket")
+		errs = append(errs, validateHTTPURL(gitConfig.Bitbucket.URL, bitbucket.Child("url"))...)
+		if gitConfig.Bitbucket.ProjectKey == "" {
+			errs = append(errs, field.Required(bitbucket.Child("projectKey"), ""))
+		}
+		if gitConfig.Bitbucket.Username == "" {
+			errs = append(errs, field.Required(bitbucket.Child("username"), ""))
+		}
+		errs = append(errs, requireCredential(bitbucket, "token", gitConfig.Bitbucket.Token,
+			gitConfig.Bitbucket.TokenSecretName)...)
+	default:
+		errs = append(errs, field.NotSupported(path.Child("provider"), gitConfig.Provider, GitProviders))
+	}
+	return errs
+}
+
+// ValidateGitSSHConfig checks that the SSH access to the deployment repository names
+// its clone URL, private key and known hosts, host keys are always verified
+func ValidateGitSSHConfig(ssh *GitSSHConfig, path *field.Path) field.ErrorList {
+	if ssh == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	errs = append(errs, validateSSHURL(ssh.CloneURL, path.Child("cloneUrl"))...)
+	if ssh.PrivateKeySecretName == "" {
+		errs = append(errs, field.Required(path.Child("privateKeySecretName"), ""))
+	}
+	if ssh.KnownHostsSecretName == "" {
+		errs = append(errs, field.Required(path.Child("knownHostsSecretName"), "host keys are always verified"))
+	}
+	return errs
+}
+
+// ValidateCDConfig checks that only the configuration block of the selected engine is
+// set and that the charts can be fetched from spec.chartRepo
+func ValidateCDConfig(spec GenezioManagerSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	flux := path.Child("cd", "flux")
+	switch spec.CD.Engine {
+	case "", "argocd":
+		if spec.CD.Flux != nil {
+			errs = append(errs, field.Forbidden(flux, "must not be set with the argocd engine"))
+		}
+	case "flux":
+		if spec.CD.Flux == nil {
+			errs = append(errs, field.Required(flux, "required with the flux engine"))
+		}
+		if !reflect.DeepEqual(spec.ArgoCDConfig, ArgoCDConfig{}) {
+			errs = append(errs, field.Forbidden(path.Child("argocdConfig"), "must not be set with the flux engine"))
+		}
+	default:
+		errs = append(errs, field.NotSupported(path.Child("cd", "engine"), spec.CD.Engine,
+			[]string{"argocd", "flux"}))
+	}
+	if spec.ChartRepo != "" {
+		errs = append(errs, validateRepositoryURL(spec.ChartRepo, path.Child("chartRepo"))...)
+	}
+	return errs
+}
+
+// ValidateArgoCDConfig checks the URL of ArgoCD and, when the operator logs in with a
+// username, that it has everything needed to generate the token
+func ValidateArgoCDConfig(argoCDConfig ArgoCDConfig, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if argoCDConfig.URL != "" || argoCDConfig.Username != "" {
+		errs = append(errs, validateHTTPURL(argoCDConfig.URL, path.Child("url"))...)
+	}
+	if argoCDConfig.Username != "" {
+		errs = append(errs, requireCredential(path, "password", argoCDConfig.Password,
+			argoCDConfig.PasswordSecretName)...)
+		if argoCDConfig.TokenType == "project" && argoCDConfig.Role == "" {
+			errs = append(errs, field.Required(path.Child("role"), "required for project tokens"))
+		}
+		if argoCDConfig.TokenType == "project" && argoCDConfig.Project == "" && argoCDConfig.AppProject == nil {
+			errs = append(errs, field.Required(path.Child("project"),
+				fmt.Sprintf("required for project tokens without %s", path.Child("appProject"))))
+		}
+	}
+	if appProject := argoCDConfig.AppProject; appProject != nil {
+		for i, destination := range appProject.Destinations {
+			if destination.Server != "" {
+				errs = append(errs, validateHTTPURL(destination.Server,
+					path.Child("appProject", "destinations").Index(i).Child("server"))...)
+			}
+		}
+	}
+	return errs
+}
+
+// ValidateRegistryConfig checks the URL of the container registry and that the
+// namespaces the image pull Secret is replicated to can be selected
+func ValidateRegistryConfig(registryConfig ContainerRegistryConfig, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if registryConfig.URL != "" {
+		errs = append(errs, validateRegistryURL(registryConfig.URL, path.Child("url"))...)
+	}
+	if replication := registryConfig.PullSecretReplication; replication != nil &&
+		replication.NamespaceSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(replication.NamespaceSelector); err != nil {
+			errs = append(errs, field.Invalid(path.Child("pullSecretReplication", "namespaceSelector"),
+				replication.NamespaceSelector, err.Error()))
+		}
+	}
+	return errs
+}
+
+// ValidateDomain checks that the domain the applications are published under is a
+// DNS subdomain
+func ValidateDomain(domain string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if domain != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(domain) {
+			errs = append(errs, field.Invalid(path, domain, msg))
+		}
+	}
+	return errs
+}
+
+// ValidateCredentials checks that every credential of the spec is given either inline
+// or as a complete Secret reference
+func ValidateCredentials(spec GenezioManagerSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	gitConfig := spec.GitConfig
+	git := path.Child("gitConfig")
+	switch gitConfig.Provider {
+	case "gitea":
+		gitea := git.Child("gitea")
+		errs = append(errs, ValidateCredential(gitea, "token", gitConfig.Gitea.Token,
+			gitConfig.Gitea.TokenSecretName, gitConfig.Gitea.TokenSecretKey)...)
+		errs = append(errs, ValidateCredential(gitea, "password", gitConfig.Gitea.Password,
+			gitConfig.Gitea.PasswordSecretName, gitConfig.Gitea.PasswordSecretKey)...)
+	case "github":
+		errs = append(errs, ValidateCredential(git.Child("github"), "token", gitConfig.GitHub.Token,
+			gitConfig.GitHub.TokenSecretName, gitConfig.GitHub.TokenSecretKey)...)
+	case "gitlab":
+		gitlab := git.Child("gitlab")
+		errs = append(errs, ValidateCredential(gitlab, "token", gitConfig.GitLab.Token,
+			gitConfig.GitLab.TokenSecretName, gitConfig.GitLab.TokenSecretKey)...)
+		errs = append(errs, ValidateCredential(gitlab, "ca", "",
+			gitConfig.GitLab.CASecretName, gitConfig.GitLab.CASecretKey)...)
+	case "bitbucket":
+		errs = append(errs, ValidateCredential(git.Child("bitbucket"), "token", gitConfig.Bitbucket.Token,
+			gitConfig.Bitbucket.TokenSecretName, gitConfig.Bitbucket.TokenSecretKey)...)
+	}
+	errs = append(errs, ValidateCredential(path.Child("argocdConfig"), "password", spec.ArgoCDConfig.Password,
+		spec.ArgoCDConfig.PasswordSecretName, spec.ArgoCDConfig.PasswordSecretKey)...)
+	errs = append(errs, ValidateCredential(path.Child("containerRegistryConfig"), "password",
+		spec.ContainerRegistryConfig.Password, spec.ContainerRegistryConfig.PasswordSecretName,
+		spec.ContainerRegistryConfig.PasswordSecretKey)...)
+	return errs
+}
+
+// ValidateCredential checks that a credential is given either inline or as a complete
+// Secret reference, e.g. password or passwordSecretName with passwordSecretKey
+func ValidateCredential(path *field.Path, name, value, secretName, secretKey string) field.ErrorList {
+	var errs field.ErrorList
+	if value != "" && secretName != "" {
+		errs = append(errs, field.Forbidden(path.Child(name+"SecretName"),
+			fmt.Sprintf("must not be set together with %s", path.Child(name))))
+	}
+	if secretName != "" && secretKey == "" {
+		errs = append(errs, field.Required(path.Child(name+"SecretKey"),
+			fmt.Sprintf("required when %s is set", path.Child(name+"SecretName"))))
+	}
+	if secretName == "" && secretKey != "" {
+		errs = append(errs, field.Required(path.Child(name+"SecretName"),
+			fmt.Sprintf("required when %s is set", path.Child(name+"SecretKey"))))
+	}
+	return errs
+}
+
+// requireCredential checks that a credential which has to be given is set inline or
+// as a Secret reference
+func requireCredential(path *field.Path, name, value, secretName string) field.ErrorList {
+	if value == "" && secretName == "" {
+		return field.ErrorList{field.Required(path.Child(name),
+			fmt.Sprintf("%s or %s is required", path.Child(name), path.Child(name+"SecretName")))}
+	}
+	return nil
+}
+
+// validateHTTPURL checks that the value is an absolute http(s) URL
+func validateHTTPURL(value string, path *field.Path) field.ErrorList {
+	if value == "" {
+		return field.ErrorList{field.Required(path, "")}
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return field.ErrorList{field.Invalid(path, value, "must be an absolute http(s) URL")}
+	}
+	return nil
+}
+
+// scpLikeURL matches the scp-like syntax of git SSH URLs, e.g. git@host:owner/repo.git
+var scpLikeURL = regexp.MustCompile(`^(?:[^@/:\s]+@)?[^@/:\s]+:[^\s]+$`)
+
+// validateSSHURL checks that the value is an ssh:// URL or uses the scp-like syntax
+func validateSSHURL(value string, path *field.Path) field.ErrorList {
+	if value == "" {
+		return field.ErrorList{field.Required(path, "")}
+	}
+	if strings.Contains(value, "://") {
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme != "ssh" || u.Host == "" {
+			return field.ErrorList{field.Invalid(path, value, "must be an ssh:// URL or of the form user@host:path")}
+		}
+		return nil
+	}
+	if !scpLikeURL.MatchString(value) {
+		return field.ErrorList{field.Invalid(path, value, "must be an ssh:// URL or of the form user@host:path")}
+	}
+	return nil
+}
+
+// validateRepositoryURL checks that the value is a git URL served over http(s) or SSH
+func validateRepositoryURL(value string, path *field.Path) field.ErrorList {
+	if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+		return validateHTTPURL(value, path)
+	}
+	if validateSSHURL(value, path) != nil {
+		return field.ErrorList{field.Invalid(path, value, "must be an http(s) or SSH git URL")}
+	}
+	return nil
+}
+
+// validateRegistryURL checks that the value names a registry host, optionally with an
+// http(s) scheme and a path, e.g. registry.example.com:5000 or https://ghcr.io
+func validateRegistryURL(value string, path *field.Path) field.ErrorList {
+	withScheme := value
+	if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+		withScheme = "https://" + value
+	}
+	u, err := url.Parse(withScheme)
+	if err != nil || u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" ||
+		strings.Contains(u.Host+u.Path, "://") {
+		return field.ErrorList{field.Invalid(path, value,
+			"must be a registry host, optionally with an http(s) scheme, e.g. registry.example.com:5000")}
+	}
+	return nil
+}
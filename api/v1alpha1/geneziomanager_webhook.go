@@ -0,0 +1,231 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Genez-io/genezio-operator/api/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var geneziomanagerlog = logf.Log.WithName("geneziomanager-resource")
+
+// Defaults applied by the defaulting webhook
+const (
+	DefaultContainerPort = 8080
+	DefaultGitProvider   = "gitea"
+	DefaultChartRev      = "HEAD"
+	DefaultDomain        = "local"
+)
+
+// GitProviders are the git providers supported by the operator
+var GitProviders = []string{"bitbucket", "gitea", "github", "gitlab"}
+
+// SetupWebhookWithManager registers the defaulting and validating webhooks of GenezioManager
+func (r *GenezioManager) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithValidator(&genezioManagerValidator{}).
+		Complete()
+}
+
+// The webhooks match the requests made with v1beta1 too, the API server converts the
+// object to v1alpha1 before calling them. The errors of the validating webhook are
+// reported with the v1beta1 field paths for those requests.
+
+//+kubebuilder:webhook:path=/mutate-init-genezio-com-v1alpha1-geneziomanager,mutating=true,failurePolicy=fail,sideEffects=None,groups=init.genezio.com,resources=geneziomanagers,verbs=create;update,versions=v1alpha1,name=mgeneziomanager.kb.io,admissionReviewVersions=v1,matchPolicy=Equivalent
+
+var _ webhook.Defaulter = &GenezioManager{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *GenezioManager) Default() {
+	geneziomanagerlog.Info("default", "name", r.Name)
+
+	if r.Spec.ContainerPort == 0 {
+		r.Spec.ContainerPort = DefaultContainerPort
+	}
+	if r.Spec.GitConfig.Provider == "" {
+		r.Spec.GitConfig.Provider = DefaultGitProvider
+	}
+	if r.Spec.ChartRev == "" {
+		r.Spec.ChartRev = DefaultChartRev
+	}
+	if r.Spec.Domain == "" {
+		r.Spec.Domain = DefaultDomain
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-init-genezio-com-v1alpha1-geneziomanager,mutating=false,failurePolicy=fail,sideEffects=None,groups=init.genezio.com,resources=geneziomanagers,verbs=create;update,versions=v1alpha1,name=vgeneziomanager.kb.io,admissionReviewVersions=v1,matchPolicy=Equivalent
+
+var _ webhook.Validator = &GenezioManager{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *GenezioManager) ValidateCreate() (admission.Warnings, error) {
+	geneziomanagerlog.Info("validate create", "name", r.Name)
+
+	return nil, r.invalid(r.validateSpec())
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+// The spec is only validated when it changes, so that objects stored before a check
+// was added can still get their finalizer and status updated.
+func (r *GenezioManager) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
+	geneziomanagerlog.Info("validate update", "name", r.Name)
+
+	// The finalizer of a GenezioManager being deleted has to be removable
+	if r.DeletionTimestamp != nil {
+		return nil, nil
+	}
+	oldGenezioManager, ok := old.(*GenezioManager)
+	if !ok {
+		return nil, r.invalid(r.validateSpec())
+	}
+
+	errs := r.validateImmutableFields(oldGenezioManager)
+	if !reflect.DeepEqual(oldGenezioManager.Spec, r.Spec) {
+		errs = append(errs, r.validateSpec()...)
+	}
+	return nil, r.invalid(errs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *GenezioManager) ValidateDelete() (admission.Warnings, error) {
+	return nil, nil
+}
+
+// genezioManagerValidator validates GenezioManagers through the webhook.Validator
+// methods, naming the fields of the API version the request was made with
+type genezioManagerValidator struct{}
+
+var _ admission.CustomValidator = &genezioManagerValidator{}
+
+// ValidateCreate implements admission.CustomValidator
+func (v *genezioManagerValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	geneziomanager, ok := obj.(*GenezioManager)
+	if !ok {
+		return nil, fmt.Errorf("expected a GenezioManager, got %T", obj)
+	}
+	warnings, err := geneziomanager.ValidateCreate()
+	return warnings, inRequestVersion(ctx, err)
+}
+
+// ValidateUpdate implements admission.CustomValidator
+func (v *genezioManagerValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	geneziomanager, ok := newObj.(*GenezioManager)
+	if !ok {
+		return nil, fmt.Errorf("expected a GenezioManager, got %T", newObj)
+	}
+	warnings, err := geneziomanager.ValidateUpdate(oldObj)
+	return warnings, inRequestVersion(ctx, err)
+}
+
+// ValidateDelete implements admission.CustomValidator
+func (v *genezioManagerValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	geneziomanager, ok := obj.(*GenezioManager)
+	if !ok {
+		return nil, fmt.Errorf("expected a GenezioManager, got %T", obj)
+	}
+	warnings, err := geneziomanager.ValidateDelete()
+	return warnings, inRequestVersion(ctx, err)
+}
+
+// v1beta1FieldPaths renames the v1alpha1 fields reported by the validation to their
+// v1beta1 counterparts, the flat Secret names and keys became Secret references
+var v1beta1FieldPaths = strings.NewReplacer(
+	".deployementRepoName", ".deploymentRepoName",
+	".tokenSecretName", ".tokenSecretRef.name",
+	".tokenSecretKey", ".tokenSecretRef.key",
+	".passwordSecretName", ".passwordSecretRef.name",
+	".passwordSecretKey", ".passwordSecretRef.key",
+	".caSecretName", ".caSecretRef.name",
+	".caSecretKey", ".caSecretRef.key",
+	".privateKeySecretName", ".privateKeySecretRef.name",
+	".privateKeySecretKey", ".privateKeySecretRef.key",
+	".knownHostsSecretName", ".knownHostsSecretRef.name",
+	".knownHostsSecretKey", ".knownHostsSecretRef.key",
+)
+
+// inRequestVersion rewrites the field paths of an Invalid error for the requests
+// made with v1beta1, which reach the webhook converted to v1alpha1
+func inRequestVersion(ctx context.Context, err error) error {
+	statusErr, ok := err.(*apierrors.StatusError)
+	if !ok {
+		return err
+	}
+	req, reqErr := admission.RequestFromContext(ctx)
+	if reqErr != nil || req.RequestKind == nil || req.RequestKind.Version != v1beta1.GroupVersion.Version {
+		return err
+	}
+
+	status := statusErr.Status()
+	status.Message = v1beta1FieldPaths.Replace(status.Message)
+	if status.Details != nil {
+		for i := range status.Details.Causes {
+			cause := &status.Details.Causes[i]
+			cause.Field = v1beta1FieldPaths.Replace(cause.Field)
+			cause.Message = v1beta1FieldPaths.Replace(cause.Message)
+		}
+	}
+	return &apierrors.StatusError{ErrStatus: status}
+}
+
+// invalid wraps the field errors into the Invalid status error reported by kubectl
+func (r *GenezioManager) invalid(errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("GenezioManager").GroupKind(), r.Name, errs)
+}
+
+// validateImmutableFields rejects changes to the fields the genezio-manager cannot follow
+func (r *GenezioManager) validateImmutableFields(old *GenezioManager) field.ErrorList {
+	var errs field.ErrorList
+	if r.Spec.Region != old.Spec.Region {
+		errs = append(errs, field.Invalid(field.NewPath("spec", "region"), r.Spec.Region, "field is immutable"))
+	}
+	return errs
+}
+
+// validateSpec checks the spec the same way the controller does, reporting every
+// problem with the path of the offending field
+func (r *GenezioManager) validateSpec() field.ErrorList {
+	spec := field.NewPath("spec")
+	errs := ValidateGitConfig(r.Spec.GitConfig, spec.Child("gitConfig"))
+	errs = append(errs, ValidateGitSSHConfig(r.Spec.GitConfig.SSH, spec.Child("gitConfig", "ssh"))...)
+	errs = append(errs, ValidateCDConfig(r.Spec, spec)...)
+	errs = append(errs, ValidateArgoCDConfig(r.Spec.ArgoCDConfig, spec.Child("argocdConfig"))...)
+	errs = append(errs, ValidateRegistryConfig(r.Spec.ContainerRegistryConfig, spec.Child("containerRegistryConfig"))...)
+	errs = append(errs, ValidateCredentials(r.Spec, spec)...)
+
+	if r.Spec.ContainerPort < 1 || r.Spec.ContainerPort > 65535 {
+		errs = append(errs, field.Invalid(spec.Child("containerPort"), r.Spec.ContainerPort,
+			"must be a port number between 1 and 65535"))
+	}
+	errs = append(errs, ValidateDomain(r.Spec.Domain, spec.Child("domain"))...)
+	return errs
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Genez-io/genezio-operator/api/v1beta1"
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func validGenezioManager() *GenezioManager {
+	return &GenezioManager{
+		ObjectMeta: metav1.ObjectMeta{Name: "manager", Namespace: "default"},
+		Spec: GenezioManagerSpec{
+			GitConfig: GitConfig{
+				Provider:            "gitea",
+				DeployementRepoName: "deployments",
+				Gitea: GiteaProvider{
+					URL:      "https://gitea.example.com",
+					Username: "genezio",
+					Token:    "token",
+				},
+			},
+			ContainerRegistryConfig: ContainerRegistryConfig{URL: "registry.example.com", Username: "genezio"},
+			Region:                  "eu-central-1",
+			ContainerPort:           8080,
+		},
+	}
+}
+
+// invalidFields returns the paths of the fields rejected by the error of a webhook
+func invalidFields(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	statusErr, ok := err.(*apierrors.StatusError)
+	if !ok || !apierrors.IsInvalid(err) {
+		t.Fatalf("expected an Invalid error, got %v", err)
+	}
+	var fields []string
+	for _, cause := range statusErr.ErrStatus.Details.Causes {
+		fields = append(fields, cause.Field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func TestGenezioManagerDefault(t *testing.T) {
+	geneziomanager := &GenezioManager{}
+	geneziomanager.Default()
+	spec := geneziomanager.Spec
+	if spec.ContainerPort != DefaultContainerPort || spec.GitConfig.Provider != DefaultGitProvider ||
+		spec.ChartRev != DefaultChartRev || spec.Domain != DefaultDomain {
+		t.Fatalf("unexpected defaults %+v", spec)
+	}
+
+	geneziomanager = validGenezioManager()
+	geneziomanager.Spec.Domain = "apps.example.com"
+	geneziomanager.Default()
+	if geneziomanager.Spec.Domain != "apps.example.com" || geneziomanager.Spec.ContainerPort != 8080 {
+		t.Fatalf("defaulting overwrote the spec %+v", geneziomanager.Spec)
+	}
+}
+
+func TestGenezioManagerValidateCreate(t *testing.T) {
+	if _, err := validGenezioManager().ValidateCreate(); err != nil {
+		t.Fatalf("unexpected error for a valid spec: %v", err)
+	}
+
+	geneziomanager := validGenezioManager()
+	geneziomanager.Spec.GitConfig.Gitea.URL = "gitea.example.com"
+	geneziomanager.Spec.GitConfig.Gitea.TokenSecretName = "credentials"
+	geneziomanager.Spec.ContainerRegistryConfig.PasswordSecretKey = "password"
+	geneziomanager.Spec.Domain = "Apps.example.com"
+	_, err := geneziomanager.ValidateCreate()
+	expected := []string{
+		"spec.containerRegistryConfig.passwordSecretName",
+		"spec.domain",
+		"spec.gitConfig.gitea.tokenSecretKey",
+		"spec.gitConfig.gitea.tokenSecretName",
+		"spec.gitConfig.gitea.url",
+	}
+	fields := invalidFields(t, err)
+	if len(fields) != len(expected) {
+		t.Fatalf("expected errors on %v, got %v", expected, fields)
+	}
+	for i := range expected {
+		if fields[i] != expected[i] {
+			t.Fatalf("expected errors on %v, got %v", expected, fields)
+		}
+	}
+
+	geneziomanager = validGenezioManager()
+	geneziomanager.Spec.GitConfig.Provider = "github"
+	_, err = geneziomanager.ValidateCreate()
+	if fields := invalidFields(t, err); len(fields) != 2 ||
+		fields[0] != "spec.gitConfig.github.owner" || fields[1] != "spec.gitConfig.github.token" {
+		t.Fatalf("expected the github block to be required, got %v", fields)
+	}
+
+	geneziomanager.Spec.GitConfig.Provider = "svn"
+	_, err = geneziomanager.ValidateCreate()
+	if fields := invalidFields(t, err); len(fields) != 1 || fields[0] != "spec.gitConfig.provider" {
+		t.Fatalf("expected the provider to be rejected, got %v", fields)
+	}
+
+	geneziomanager = validGenezioManager()
+	geneziomanager.Spec.GitConfig.SSH = &GitSSHConfig{CloneURL: "git@gitea.example.com:genezio/deployments.git",
+		PrivateKeySecretName: "ssh"}
+	geneziomanager.Spec.CD = CDConfig{Engine: "flux"}
+	geneziomanager.Spec.ArgoCDConfig.URL = "https://argocd.example.com"
+	_, err = geneziomanager.ValidateCreate()
+	if fields := invalidFields(t, err); len(fields) != 3 || fields[0] != "spec.argocdConfig" ||
+		fields[1] != "spec.cd.flux" || fields[2] != "spec.gitConfig.ssh.knownHostsSecretName" {
+		t.Fatalf("expected the ssh and flux blocks to be checked, got %v", fields)
+	}
+
+	geneziomanager = validGenezioManager()
+	geneziomanager.Spec.CD.Flux = &FluxConfig{}
+	_, err = geneziomanager.ValidateCreate()
+	if fields := invalidFields(t, err); len(fields) != 1 || fields[0] != "spec.cd.flux" {
+		t.Fatalf("expected spec.cd.flux to be rejected with the argocd engine, got %v", fields)
+	}
+}
+
+func TestGenezioManagerValidateV1beta1Request(t *testing.T) {
+	geneziomanager := validGenezioManager()
+	geneziomanager.Spec.GitConfig.DeployementRepoName = ""
+	geneziomanager.Spec.GitConfig.Gitea.PasswordSecretName = "gitea"
+
+	validator := &genezioManagerValidator{}
+	_, err := validator.ValidateCreate(context.Background(), geneziomanager)
+	if fields := invalidFields(t, err); len(fields) != 2 || fields[0] != "spec.gitConfig.deployementRepoName" ||
+		fields[1] != "spec.gitConfig.gitea.passwordSecretKey" {
+		t.Fatalf("expected the v1alpha1 fields to be rejected, got %v", fields)
+	}
+
+	gvk := v1beta1.GroupVersion.WithKind("GenezioManager")
+	ctx := admission.NewContextWithRequest(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			RequestKind: &metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind},
+		},
+	})
+	_, err = validator.ValidateCreate(ctx, geneziomanager)
+	if fields := invalidFields(t, err); len(fields) != 2 || fields[0] != "spec.gitConfig.deploymentRepoName" ||
+		fields[1] != "spec.gitConfig.gitea.passwordSecretRef.key" {
+		t.Fatalf("expected the v1beta1 fields to be rejected, got %v", fields)
+	}
+	if msg := err.Error(); strings.Contains(msg, "deployementRepoName") ||
+		!strings.Contains(msg, "required when spec.gitConfig.gitea.passwordSecretRef.name is set") {
+		t.Errorf("expected the message to name the v1beta1 fields, got %q", msg)
+	}
+}
+
+func TestGenezioManagerValidateUpdate(t *testing.T) {
+	old := validGenezioManager()
+	geneziomanager := validGenezioManager()
+	geneziomanager.Spec.ChartRev = "v2"
+	if _, err := geneziomanager.ValidateUpdate(old); err != nil {
+		t.Fatalf("unexpected error for a mutable field: %v", err)
+	}
+
+	geneziomanager.Spec.Region = "us-east-1"
+	_, err := geneziomanager.ValidateUpdate(old)
+	if fields := invalidFields(t, err); len(fields) != 1 || fields[0] != "spec.region" {
+		t.Fatalf("expected spec.region to be immutable, got %v", fields)
+	}
+
+	// A spec stored before a check was added only fails once it changes
+	old = validGenezioManager()
+	old.Spec.Domain = "Apps.example.com"
+	geneziomanager = old.DeepCopy()
+	geneziomanager.Finalizers = []string{"init.genezio.com/finalizer"}
+	if _, err := geneziomanager.ValidateUpdate(old); err != nil {
+		t.Fatalf("unexpected error for an unchanged spec: %v", err)
+	}
+	geneziomanager.Spec.ChartRev = "v2"
+	_, err = geneziomanager.ValidateUpdate(old)
+	if fields := invalidFields(t, err); len(fields) != 1 || fields[0] != "spec.domain" {
+		t.Fatalf("expected spec.domain to be rejected once the spec changes, got %v", fields)
+	}
+
+	// The finalizer of a GenezioManager being deleted can always be removed
+	now := metav1.Now()
+	geneziomanager.DeletionTimestamp = &now
+	geneziomanager.Spec.Region = "us-east-1"
+	if _, err := geneziomanager.ValidateUpdate(old); err != nil {
+		t.Fatalf("unexpected error for a GenezioManager being deleted: %v", err)
+	}
+}
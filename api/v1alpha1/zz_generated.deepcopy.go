@@ -22,12 +22,27 @@ package v1alpha1
 
 import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ArgoCDConfig) DeepCopyInto(out *ArgoCDConfig) {
 	*out = *in
+	if in.TokenTTL != nil {
+		in, out := &in.TokenTTL, &out.TokenTTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Repository != nil {
+		in, out := &in.Repository, &out.Repository
+		*out = new(ArgoCDRepositoryConfig)
+		**out = **in
+	}
+	if in.AppProject != nil {
+		in, out := &in.AppProject, &out.AppProject
+		*out = new(ArgoCDProjectConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArgoCDConfig.
@@ -40,9 +55,104 @@ func (in *ArgoCDConfig) DeepCopy() *ArgoCDConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArgoCDProjectConfig) DeepCopyInto(out *ArgoCDProjectConfig) {
+	*out = *in
+	if in.Destinations != nil {
+		in, out := &in.Destinations, &out.Destinations
+		*out = make([]ArgoCDProjectDestination, len(*in))
+		copy(*out, *in)
+	}
+	if in.ClusterResourceWhitelist != nil {
+		in, out := &in.ClusterResourceWhitelist, &out.ClusterResourceWhitelist
+		*out = make([]v1.GroupKind, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArgoCDProjectConfig.
+func (in *ArgoCDProjectConfig) DeepCopy() *ArgoCDProjectConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ArgoCDProjectConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArgoCDProjectDestination) DeepCopyInto(out *ArgoCDProjectDestination) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArgoCDProjectDestination.
+func (in *ArgoCDProjectDestination) DeepCopy() *ArgoCDProjectDestination {
+	if in == nil {
+		return nil
+	}
+	out := new(ArgoCDProjectDestination)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArgoCDRepositoryConfig) DeepCopyInto(out *ArgoCDRepositoryConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArgoCDRepositoryConfig.
+func (in *ArgoCDRepositoryConfig) DeepCopy() *ArgoCDRepositoryConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ArgoCDRepositoryConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BitbucketProvider) DeepCopyInto(out *BitbucketProvider) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BitbucketProvider.
+func (in *BitbucketProvider) DeepCopy() *BitbucketProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(BitbucketProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CDConfig) DeepCopyInto(out *CDConfig) {
+	*out = *in
+	if in.Flux != nil {
+		in, out := &in.Flux, &out.Flux
+		*out = new(FluxConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CDConfig.
+func (in *CDConfig) DeepCopy() *CDConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(CDConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ContainerRegistryConfig) DeepCopyInto(out *ContainerRegistryConfig) {
 	*out = *in
+	if in.PullSecretReplication != nil {
+		in, out := &in.PullSecretReplication, &out.PullSecretReplication
+		*out = new(PullSecretReplication)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerRegistryConfig.
@@ -55,12 +165,47 @@ func (in *ContainerRegistryConfig) DeepCopy() *ContainerRegistryConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeploymentRepositoryStatus) DeepCopyInto(out *DeploymentRepositoryStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeploymentRepositoryStatus.
+func (in *DeploymentRepositoryStatus) DeepCopy() *DeploymentRepositoryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DeploymentRepositoryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FluxConfig) DeepCopyInto(out *FluxConfig) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FluxConfig.
+func (in *FluxConfig) DeepCopy() *FluxConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(FluxConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GenezioManager) DeepCopyInto(out *GenezioManager) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -117,9 +262,21 @@ func (in *GenezioManagerList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GenezioManagerSpec) DeepCopyInto(out *GenezioManagerSpec) {
 	*out = *in
-	out.ArgoCDConfig = in.ArgoCDConfig
-	out.GitConfig = in.GitConfig
-	out.ContainerRegistryConfig = in.ContainerRegistryConfig
+	in.ArgoCDConfig.DeepCopyInto(&out.ArgoCDConfig)
+	in.GitConfig.DeepCopyInto(&out.GitConfig)
+	in.ContainerRegistryConfig.DeepCopyInto(&out.ContainerRegistryConfig)
+	in.Service.DeepCopyInto(&out.Service)
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(IngressConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	in.CD.DeepCopyInto(&out.CD)
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(ImageConfig)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GenezioManagerSpec.
@@ -142,6 +299,28 @@ func (in *GenezioManagerStatus) DeepCopyInto(out *GenezioManagerStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.DeploymentRepository != nil {
+		in, out := &in.DeploymentRepository, &out.DeploymentRepository
+		*out = new(DeploymentRepositoryStatus)
+		**out = **in
+	}
+	if in.GiteaTokenRotationTime != nil {
+		in, out := &in.GiteaTokenRotationTime, &out.GiteaTokenRotationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ArgoCDTokenExpirationTime != nil {
+		in, out := &in.ArgoCDTokenExpirationTime, &out.ArgoCDTokenExpirationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.DependenciesCheckTime != nil {
+		in, out := &in.DependenciesCheckTime, &out.DependenciesCheckTime
+		*out = (*in).DeepCopy()
+	}
+	if in.SSHHostKeys != nil {
+		in, out := &in.SSHHostKeys, &out.SSHHostKeys
+		*out = make([]SSHHostKey, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GenezioManagerStatus.
@@ -157,7 +336,15 @@ func (in *GenezioManagerStatus) DeepCopy() *GenezioManagerStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitConfig) DeepCopyInto(out *GitConfig) {
 	*out = *in
-	out.Gitea = in.Gitea
+	in.Gitea.DeepCopyInto(&out.Gitea)
+	out.GitHub = in.GitHub
+	out.GitLab = in.GitLab
+	out.Bitbucket = in.Bitbucket
+	if in.SSH != nil {
+		in, out := &in.SSH, &out.SSH
+		*out = new(GitSSHConfig)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitConfig.
@@ -185,9 +372,64 @@ func (in *GitConfigGenezio) DeepCopy() *GitConfigGenezio {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitHubProvider) DeepCopyInto(out *GitHubProvider) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitHubProvider.
+func (in *GitHubProvider) DeepCopy() *GitHubProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(GitHubProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitLabProvider) DeepCopyInto(out *GitLabProvider) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitLabProvider.
+func (in *GitLabProvider) DeepCopy() *GitLabProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(GitLabProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitSSHConfig) DeepCopyInto(out *GitSSHConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitSSHConfig.
+func (in *GitSSHConfig) DeepCopy() *GitSSHConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GitSSHConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GiteaProvider) DeepCopyInto(out *GiteaProvider) {
 	*out = *in
+	if in.TokenScopes != nil {
+		in, out := &in.TokenScopes, &out.TokenScopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TokenRotationInterval != nil {
+		in, out := &in.TokenRotationInterval, &out.TokenRotationInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GiteaProvider.
@@ -199,3 +441,102 @@ func (in *GiteaProvider) DeepCopy() *GiteaProvider {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageConfig) DeepCopyInto(out *ImageConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageConfig.
+func (in *ImageConfig) DeepCopy() *ImageConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IngressConfig) DeepCopyInto(out *IngressConfig) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IngressConfig.
+func (in *IngressConfig) DeepCopy() *IngressConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(IngressConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PullSecretReplication) DeepCopyInto(out *PullSecretReplication) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PullSecretReplication.
+func (in *PullSecretReplication) DeepCopy() *PullSecretReplication {
+	if in == nil {
+		return nil
+	}
+	out := new(PullSecretReplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SSHHostKey) DeepCopyInto(out *SSHHostKey) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHHostKey.
+func (in *SSHHostKey) DeepCopy() *SSHHostKey {
+	if in == nil {
+		return nil
+	}
+	out := new(SSHHostKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceConfig) DeepCopyInto(out *ServiceConfig) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceConfig.
+func (in *ServiceConfig) DeepCopy() *ServiceConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceConfig)
+	in.DeepCopyInto(out)
+	return out
+}
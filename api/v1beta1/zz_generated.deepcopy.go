@@ -0,0 +1,608 @@
+//go:build !ignore_autogenerated
+
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArgoCDConfig) DeepCopyInto(out *ArgoCDConfig) {
+	*out = *in
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+	if in.TokenTTL != nil {
+		in, out := &in.TokenTTL, &out.TokenTTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Repository != nil {
+		in, out := &in.Repository, &out.Repository
+		*out = new(ArgoCDRepositoryConfig)
+		**out = **in
+	}
+	if in.AppProject != nil {
+		in, out := &in.AppProject, &out.AppProject
+		*out = new(ArgoCDProjectConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArgoCDConfig.
+func (in *ArgoCDConfig) DeepCopy() *ArgoCDConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ArgoCDConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArgoCDProjectConfig) DeepCopyInto(out *ArgoCDProjectConfig) {
+	*out = *in
+	if in.Destinations != nil {
+		in, out := &in.Destinations, &out.Destinations
+		*out = make([]ArgoCDProjectDestination, len(*in))
+		copy(*out, *in)
+	}
+	if in.ClusterResourceWhitelist != nil {
+		in, out := &in.ClusterResourceWhitelist, &out.ClusterResourceWhitelist
+		*out = make([]v1.GroupKind, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArgoCDProjectConfig.
+func (in *ArgoCDProjectConfig) DeepCopy() *ArgoCDProjectConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ArgoCDProjectConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArgoCDProjectDestination) DeepCopyInto(out *ArgoCDProjectDestination) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArgoCDProjectDestination.
+func (in *ArgoCDProjectDestination) DeepCopy() *ArgoCDProjectDestination {
+	if in == nil {
+		return nil
+	}
+	out := new(ArgoCDProjectDestination)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArgoCDRepositoryConfig) DeepCopyInto(out *ArgoCDRepositoryConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArgoCDRepositoryConfig.
+func (in *ArgoCDRepositoryConfig) DeepCopy() *ArgoCDRepositoryConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ArgoCDRepositoryConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BitbucketProvider) DeepCopyInto(out *BitbucketProvider) {
+	*out = *in
+	if in.TokenSecretRef != nil {
+		in, out := &in.TokenSecretRef, &out.TokenSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BitbucketProvider.
+func (in *BitbucketProvider) DeepCopy() *BitbucketProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(BitbucketProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CDConfig) DeepCopyInto(out *CDConfig) {
+	*out = *in
+	if in.Flux != nil {
+		in, out := &in.Flux, &out.Flux
+		*out = new(FluxConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CDConfig.
+func (in *CDConfig) DeepCopy() *CDConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(CDConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerRegistryConfig) DeepCopyInto(out *ContainerRegistryConfig) {
+	*out = *in
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+	if in.PullSecretReplication != nil {
+		in, out := &in.PullSecretReplication, &out.PullSecretReplication
+		*out = new(PullSecretReplication)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerRegistryConfig.
+func (in *ContainerRegistryConfig) DeepCopy() *ContainerRegistryConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerRegistryConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeploymentRepositoryStatus) DeepCopyInto(out *DeploymentRepositoryStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeploymentRepositoryStatus.
+func (in *DeploymentRepositoryStatus) DeepCopy() *DeploymentRepositoryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DeploymentRepositoryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FluxConfig) DeepCopyInto(out *FluxConfig) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FluxConfig.
+func (in *FluxConfig) DeepCopy() *FluxConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(FluxConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GenezioManager) DeepCopyInto(out *GenezioManager) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GenezioManager.
+func (in *GenezioManager) DeepCopy() *GenezioManager {
+	if in == nil {
+		return nil
+	}
+	out := new(GenezioManager)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GenezioManager) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GenezioManagerList) DeepCopyInto(out *GenezioManagerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GenezioManager, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GenezioManagerList.
+func (in *GenezioManagerList) DeepCopy() *GenezioManagerList {
+	if in == nil {
+		return nil
+	}
+	out := new(GenezioManagerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GenezioManagerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GenezioManagerSpec) DeepCopyInto(out *GenezioManagerSpec) {
+	*out = *in
+	in.ArgoCDConfig.DeepCopyInto(&out.ArgoCDConfig)
+	in.GitConfig.DeepCopyInto(&out.GitConfig)
+	in.ContainerRegistryConfig.DeepCopyInto(&out.ContainerRegistryConfig)
+	in.Service.DeepCopyInto(&out.Service)
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(IngressConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	in.CD.DeepCopyInto(&out.CD)
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(ImageConfig)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GenezioManagerSpec.
+func (in *GenezioManagerSpec) DeepCopy() *GenezioManagerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GenezioManagerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GenezioManagerStatus) DeepCopyInto(out *GenezioManagerStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DeploymentRepository != nil {
+		in, out := &in.DeploymentRepository, &out.DeploymentRepository
+		*out = new(DeploymentRepositoryStatus)
+		**out = **in
+	}
+	if in.GiteaTokenRotationTime != nil {
+		in, out := &in.GiteaTokenRotationTime, &out.GiteaTokenRotationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ArgoCDTokenExpirationTime != nil {
+		in, out := &in.ArgoCDTokenExpirationTime, &out.ArgoCDTokenExpirationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.DependenciesCheckTime != nil {
+		in, out := &in.DependenciesCheckTime, &out.DependenciesCheckTime
+		*out = (*in).DeepCopy()
+	}
+	if in.SSHHostKeys != nil {
+		in, out := &in.SSHHostKeys, &out.SSHHostKeys
+		*out = make([]SSHHostKey, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GenezioManagerStatus.
+func (in *GenezioManagerStatus) DeepCopy() *GenezioManagerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GenezioManagerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitConfig) DeepCopyInto(out *GitConfig) {
+	*out = *in
+	if in.Gitea != nil {
+		in, out := &in.Gitea, &out.Gitea
+		*out = new(GiteaProvider)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GitHub != nil {
+		in, out := &in.GitHub, &out.GitHub
+		*out = new(GitHubProvider)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GitLab != nil {
+		in, out := &in.GitLab, &out.GitLab
+		*out = new(GitLabProvider)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Bitbucket != nil {
+		in, out := &in.Bitbucket, &out.Bitbucket
+		*out = new(BitbucketProvider)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SSH != nil {
+		in, out := &in.SSH, &out.SSH
+		*out = new(GitSSHConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitConfig.
+func (in *GitConfig) DeepCopy() *GitConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GitConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitHubProvider) DeepCopyInto(out *GitHubProvider) {
+	*out = *in
+	if in.TokenSecretRef != nil {
+		in, out := &in.TokenSecretRef, &out.TokenSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitHubProvider.
+func (in *GitHubProvider) DeepCopy() *GitHubProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(GitHubProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitLabProvider) DeepCopyInto(out *GitLabProvider) {
+	*out = *in
+	if in.TokenSecretRef != nil {
+		in, out := &in.TokenSecretRef, &out.TokenSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+	if in.CASecretRef != nil {
+		in, out := &in.CASecretRef, &out.CASecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitLabProvider.
+func (in *GitLabProvider) DeepCopy() *GitLabProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(GitLabProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitSSHConfig) DeepCopyInto(out *GitSSHConfig) {
+	*out = *in
+	if in.PrivateKeySecretRef != nil {
+		in, out := &in.PrivateKeySecretRef, &out.PrivateKeySecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+	if in.KnownHostsSecretRef != nil {
+		in, out := &in.KnownHostsSecretRef, &out.KnownHostsSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitSSHConfig.
+func (in *GitSSHConfig) DeepCopy() *GitSSHConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GitSSHConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GiteaProvider) DeepCopyInto(out *GiteaProvider) {
+	*out = *in
+	if in.TokenSecretRef != nil {
+		in, out := &in.TokenSecretRef, &out.TokenSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+	if in.TokenScopes != nil {
+		in, out := &in.TokenScopes, &out.TokenScopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TokenRotationInterval != nil {
+		in, out := &in.TokenRotationInterval, &out.TokenRotationInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GiteaProvider.
+func (in *GiteaProvider) DeepCopy() *GiteaProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(GiteaProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageConfig) DeepCopyInto(out *ImageConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageConfig.
+func (in *ImageConfig) DeepCopy() *ImageConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IngressConfig) DeepCopyInto(out *IngressConfig) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IngressConfig.
+func (in *IngressConfig) DeepCopy() *IngressConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(IngressConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PullSecretReplication) DeepCopyInto(out *PullSecretReplication) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PullSecretReplication.
+func (in *PullSecretReplication) DeepCopy() *PullSecretReplication {
+	if in == nil {
+		return nil
+	}
+	out := new(PullSecretReplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SSHHostKey) DeepCopyInto(out *SSHHostKey) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHHostKey.
+func (in *SSHHostKey) DeepCopy() *SSHHostKey {
+	if in == nil {
+		return nil
+	}
+	out := new(SSHHostKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeySelector) DeepCopyInto(out *SecretKeySelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeySelector.
+func (in *SecretKeySelector) DeepCopy() *SecretKeySelector {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeySelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceConfig) DeepCopyInto(out *ServiceConfig) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceConfig.
+func (in *ServiceConfig) DeepCopy() *ServiceConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceConfig)
+	in.DeepCopyInto(out)
+	return out
+}
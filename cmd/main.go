@@ -35,6 +35,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	initv1beta1 "github.com/Genez-io/genezio-operator/api/v1beta1"
 	"github.com/Genez-io/genezio-operator/internal/controller"
 	//+kubebuilder:scaffold:imports
 )
@@ -48,6 +49,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(initv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(initv1beta1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -129,6 +131,18 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "GenezioManager")
 		os.Exit(1)
 	}
+	// Webhooks are disabled when running the manager locally without certificates,
+	// GenezioManagers cannot be converted between v1alpha1 and v1beta1 then
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&initv1alpha1.GenezioManager{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "GenezioManager")
+			os.Exit(1)
+		}
+		if err = (&initv1beta1.GenezioManager{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "GenezioManager")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -0,0 +1,459 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Reasons used on the Degraded condition when the operator cannot log into ArgoCD
+const (
+	reasonInvalidArgoCDConfig = "InvalidArgoCDConfig"
+	reasonArgoCDUnauthorized  = "ArgoCDUnauthorized"
+)
+
+// argoCDAPITimeout bounds every call made by the operator to the API of ArgoCD
+const argoCDAPITimeout = 10 * time.Second
+
+// defaultArgoCDTokenTTL is the lifetime of generated account and project tokens
+const defaultArgoCDTokenTTL = 24 * time.Hour
+
+// argoCDTokenSecretKey is the key of the generated token in the managed Secret
+const argoCDTokenSecretKey = "token"
+
+// Annotations of the managed Secret describing the generated token
+const (
+	argoCDTokenSourceAnnotation    = "init.genezio.com/argocd-token-source"
+	argoCDTokenIDAnnotation        = "init.genezio.com/argocd-token-id"
+	argoCDTokenIssuedAtAnnotation  = "init.genezio.com/argocd-token-issued-at"
+	argoCDTokenExpiresAtAnnotation = "init.genezio.com/argocd-token-expires-at"
+)
+
+var (
+	// errArgoCDUnauthorized is returned when ArgoCD rejects the credentials or the session
+	errArgoCDUnauthorized = errors.New("ArgoCD rejected the credentials")
+	// errArgoCDNotFound is returned when the object addressed on the API of ArgoCD does not exist
+	errArgoCDNotFound = errors.New("not found in ArgoCD")
+)
+
+// argoCDToken is a token generated for the genezio-manager
+type argoCDToken struct {
+	token string
+	// id identifies account and project tokens so they can be revoked, session
+	// tokens have none
+	id        string
+	expiresAt time.Time
+}
+
+// usesArgoCDLogin reports whether the operator logs into ArgoCD to generate the
+// token of the genezio-manager, instead of passing the password through
+func usesArgoCDLogin(argoCDConfig initv1alpha1.ArgoCDConfig) bool {
+	return argoCDConfig.Username != ""
+}
+
+// argoCDTokenSecretName returns the name of the Secret holding the generated token
+func argoCDTokenSecretName(name string) string {
+	return name + "-argocd-token"
+}
+
+// argoCDTokenCredential references the generated token from the managed Secret
+func argoCDTokenCredential(name string) credential {
+	return credential{
+		path:       "spec.argocdConfig.password",
+		secretName: argoCDTokenSecretName(name),
+		secretKey:  argoCDTokenSecretKey,
+	}
+}
+
+// argoCDTokenSource identifies what the token was generated for, so that it is
+// generated again when the configuration changes
+func argoCDTokenSource(argoCDConfig initv1alpha1.ArgoCDConfig) string {
+	switch argoCDConfig.TokenType {
+	case "account":
+		return "account/" + argoCDAccount(argoCDConfig)
+	case "project":
+		return "project/" + argoCDConfig.Project + "/" + argoCDConfig.Role
+	default:
+		return "session/" + argoCDConfig.Username
+	}
+}
+
+func argoCDAccount(argoCDConfig initv1alpha1.ArgoCDConfig) string {
+	if argoCDConfig.Account != "" {
+		return argoCDConfig.Account
+	}
+	return argoCDConfig.Username
+}
+
+func argoCDTokenTTL(argoCDConfig initv1alpha1.ArgoCDConfig) time.Duration {
+	if argoCDConfig.TokenTTL != nil && argoCDConfig.TokenTTL.Duration > 0 {
+		return argoCDConfig.TokenTTL.Duration
+	}
+	return defaultArgoCDTokenTTL
+}
+
+// validateArgoCDConfig checks that the ArgoCD configuration allows the operator to log in
+func validateArgoCDConfig(argoCDConfig initv1alpha1.ArgoCDConfig) error {
+	return specErrorFor(reasonInvalidArgoCDConfig,
+		initv1alpha1.ValidateArgoCDConfig(argoCDConfig, field.NewPath("spec", "argocdConfig")))
+}
+
+// argoCDAPIDo performs a request against the API of ArgoCD, authenticated with the
+// bearer token if given, sending in as JSON and decoding the JSON response into out.
+// 401 and 403 are mapped to errArgoCDUnauthorized and 404 wraps errArgoCDNotFound.
+func argoCDAPIDo(ctx context.Context, method, endpoint, bearer string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	resp, err := (&http.Client{Timeout: argoCDAPITimeout}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return errArgoCDUnauthorized
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%s %s returned %s: %w", method, endpoint, resp.Status, errArgoCDNotFound)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("%s %s returned %s", method, endpoint, resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// jwtClaims holds the registered claims of a JWT read by the operator
+type jwtClaims struct {
+	Exp int64 `json:"exp"`
+	Iat int64 `json:"iat"`
+}
+
+// parseJWTClaims decodes the claims of a JWT without verifying its signature
+func parseJWTClaims(token string) (jwtClaims, bool) {
+	claims := jwtClaims{}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims, false
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, false
+	}
+	return claims, true
+}
+
+// jwtExpiration returns the expiration time held by the exp claim of a JWT, if any
+func jwtExpiration(token string) (time.Time, bool) {
+	claims, ok := parseJWTClaims(token)
+	if !ok || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
+
+// argoCDLogin logs into ArgoCD with the username of the configuration and returns
+// the session token
+func argoCDLogin(ctx context.Context, argoCDConfig initv1alpha1.ArgoCDConfig, password string) (string, error) {
+	session := struct {
+		Token string `json:"token"`
+	}{}
+	if err := argoCDAPIDo(ctx, http.MethodPost, apiURL(argoCDConfig.URL, "/api/v1/session"), "",
+		map[string]string{"username": argoCDConfig.Username, "password": password}, &session); err != nil {
+		return "", err
+	}
+	return session.Token, nil
+}
+
+// generateArgoCDToken returns the token selected by the configuration, generated
+// with the session of the operator unless the session token itself is selected
+func generateArgoCDToken(ctx context.Context, argoCDConfig initv1alpha1.ArgoCDConfig, session string,
+	now time.Time) (argoCDToken, error) {
+	var endpoint string
+	switch argoCDConfig.TokenType {
+	case "account":
+		endpoint = apiURL(argoCDConfig.URL,
+			fmt.Sprintf("/api/v1/account/%s/token", url.PathEscape(argoCDAccount(argoCDConfig))))
+	case "project":
+		endpoint = apiURL(argoCDConfig.URL, fmt.Sprintf("/api/v1/projects/%s/roles/%s/token",
+			url.PathEscape(argoCDConfig.Project), url.PathEscape(argoCDConfig.Role)))
+	default:
+		// Session tokens expire according to the settings of the ArgoCD server
+		expiresAt, ok := jwtExpiration(session)
+		if !ok {
+			expiresAt = now.Add(defaultArgoCDTokenTTL)
+		}
+		return argoCDToken{token: session, expiresAt: expiresAt}, nil
+	}
+
+	ttl := argoCDTokenTTL(argoCDConfig)
+	generated := struct {
+		Token string `json:"token"`
+	}{}
+	id := fmt.Sprintf("genezio-operator-%d", now.Unix())
+	request := map[string]interface{}{
+		"id":        id,
+		"expiresIn": int64(ttl / time.Second),
+	}
+	if err := argoCDAPIDo(ctx, http.MethodPost, endpoint, session, request, &generated); err != nil {
+		return argoCDToken{}, err
+	}
+	expiresAt, ok := jwtExpiration(generated.Token)
+	if !ok {
+		expiresAt = now.Add(ttl)
+	}
+	return argoCDToken{token: generated.Token, id: id, expiresAt: expiresAt}, nil
+}
+
+// deleteArgoCDToken deletes the account or project token held by the managed Secret
+// from ArgoCD, using the source and id recorded on the Secret. Session tokens expire
+// on their own. Tokens already gone are ignored.
+func deleteArgoCDToken(ctx context.Context, argoCDURL, session string, secret *corev1.Secret) error {
+	id := secret.Annotations[argoCDTokenIDAnnotation]
+	if id == "" {
+		return nil
+	}
+	var endpoint string
+	kind, target, _ := strings.Cut(secret.Annotations[argoCDTokenSourceAnnotation], "/")
+	switch kind {
+	case "account":
+		endpoint = apiURL(argoCDURL, fmt.Sprintf("/api/v1/account/%s/token/%s",
+			url.PathEscape(target), url.PathEscape(id)))
+	case "project":
+		// Project tokens are addressed by their issue time, the id takes precedence
+		project, role, _ := strings.Cut(target, "/")
+		claims, _ := parseJWTClaims(string(secret.Data[argoCDTokenSecretKey]))
+		endpoint = apiURL(argoCDURL, fmt.Sprintf("/api/v1/projects/%s/roles/%s/token/%d?id=%s",
+			url.PathEscape(project), url.PathEscape(role), claims.Iat, url.QueryEscape(id)))
+	default:
+		return nil
+	}
+	err := argoCDAPIDo(ctx, http.MethodDelete, endpoint, session, nil, nil)
+	if errors.Is(err, errArgoCDNotFound) {
+		return nil
+	}
+	return err
+}
+
+// argoCDTokenRefreshDelay returns when to check the generated ArgoCD token again, or
+// zero when the operator does not generate it. Checking again halfway to the
+// expiration lets the token be refreshed once two thirds of its lifetime have passed.
+func argoCDTokenRefreshDelay(geneziomanager *initv1alpha1.GenezioManager, now time.Time) time.Duration {
+	expiresAt := geneziomanager.Status.ArgoCDTokenExpirationTime
+	if !usesArgoCDLogin(geneziomanager.Spec.ArgoCDConfig) || expiresAt == nil {
+		return 0
+	}
+	if delay := expiresAt.Sub(now) / 2; delay > time.Second {
+		return delay
+	}
+	return time.Second
+}
+
+// reconcileArgoCDToken logs into ArgoCD with the username and password of the spec
+// and keeps the generated token in a Secret owned by the GenezioManager. The token
+// is generated again when the configuration changes and once two thirds of its
+// lifetime have passed, so the genezio-manager never holds an expired token. It runs
+// after reconcileArgoCDProject, which creates the role of project tokens.
+func (r *GenezioManagerReconciler) reconcileArgoCDToken(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) error {
+	log := log.FromContext(ctx)
+	argoCDConfig := geneziomanager.Spec.ArgoCDConfig
+	if !usesArgoCDLogin(argoCDConfig) {
+		geneziomanager.Status.ArgoCDTokenExpirationTime = nil
+		return nil
+	}
+	// Project tokens default to a role of the AppProject managed by the operator
+	argoCDConfig.Project = argoCDTokenProject(geneziomanager)
+
+	found := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: argoCDTokenSecretName(geneziomanager.Name),
+		Namespace: geneziomanager.Namespace}, found)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	now := time.Now()
+	issuedAt, issuedErr := time.Parse(time.RFC3339, found.Annotations[argoCDTokenIssuedAtAnnotation])
+	expiresAt, expiresErr := time.Parse(time.RFC3339, found.Annotations[argoCDTokenExpiresAtAnnotation])
+	due := !exists || len(found.Data[argoCDTokenSecretKey]) == 0 || issuedErr != nil || expiresErr != nil ||
+		found.Annotations[argoCDTokenSourceAnnotation] != argoCDTokenSource(argoCDConfig) ||
+		!now.Before(issuedAt.Add(expiresAt.Sub(issuedAt)*2/3))
+	if !due {
+		geneziomanager.Status.ArgoCDTokenExpirationTime = &metav1.Time{Time: expiresAt}
+		return nil
+	}
+
+	password, err := r.resolveCredential(ctx, geneziomanager.Namespace, credentialsFor(geneziomanager).argoCDPassword)
+	if err != nil {
+		return err
+	}
+	session, err := argoCDLogin(ctx, argoCDConfig, password)
+	var token argoCDToken
+	if err == nil {
+		token, err = generateArgoCDToken(ctx, argoCDConfig, session, now)
+	}
+	if errors.Is(err, errArgoCDUnauthorized) {
+		specErr := &specError{Reason: reasonArgoCDUnauthorized,
+			Message: fmt.Sprintf("ArgoCD rejected the credentials of %s or the generation of a %s token",
+				argoCDConfig.Username, argoCDTokenSource(argoCDConfig))}
+		meta.SetStatusCondition(&geneziomanager.Status.Conditions, metav1.Condition{
+			Type: typeArgoCDReadyGenezioManager, Status: metav1.ConditionFalse,
+			Reason: specErr.Reason, Message: specErr.Message})
+		return specErr
+	} else if err != nil {
+		return fmt.Errorf("failed to generate an ArgoCD token: %w", err)
+	}
+
+	secret := &corev1.Secret{
+		// The type information is required by server-side apply
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      argoCDTokenSecretName(geneziomanager.Name),
+			Namespace: geneziomanager.Namespace,
+			Labels:    selectorLabelsForGenezioManager(geneziomanager.Name),
+			Annotations: map[string]string{
+				argoCDTokenSourceAnnotation:    argoCDTokenSource(argoCDConfig),
+				argoCDTokenIDAnnotation:        token.id,
+				argoCDTokenIssuedAtAnnotation:  now.UTC().Format(time.RFC3339),
+				argoCDTokenExpiresAtAnnotation: token.expiresAt.UTC().Format(time.RFC3339),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{argoCDTokenSecretKey: []byte(token.token)},
+	}
+	// Set the ownerRef for the Secret so it is garbage collected with the custom resource
+	if err := ctrl.SetControllerReference(geneziomanager, secret, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Patch(ctx, secret, client.Apply, client.ForceOwnership, client.FieldOwner(fieldManager)); err != nil {
+		log.Error(err, "Failed to apply Secret", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
+		return err
+	}
+	log.Info("Generated ArgoCD token", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name,
+		"expiresAt", token.expiresAt)
+	geneziomanager.Status.ArgoCDTokenExpirationTime = &metav1.Time{Time: token.expiresAt.UTC().Truncate(time.Second)}
+
+	// The previous token is revoked once the new one is stored. A failure only leaves
+	// an unused token behind until it expires, so it does not fail the reconciliation.
+	if exists {
+		if err := deleteArgoCDToken(ctx, argoCDConfig.URL, session, found); err != nil {
+			log.Error(err, "Failed to revoke the previous ArgoCD token",
+				"token", found.Annotations[argoCDTokenIDAnnotation])
+		}
+	}
+	return nil
+}
+
+// revokeArgoCDToken revokes the token recorded on the managed Secret when the
+// GenezioManager is deleted, like revokeGiteaToken. Tokens which cannot be revoked,
+// e.g. because ArgoCD cannot be reached, are left behind until they expire.
+func (r *GenezioManagerReconciler) revokeArgoCDToken(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) error {
+	log := log.FromContext(ctx)
+	argoCDConfig := geneziomanager.Spec.ArgoCDConfig
+	found := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: argoCDTokenSecretName(geneziomanager.Name),
+		Namespace: geneziomanager.Namespace}, found)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	id := found.Annotations[argoCDTokenIDAnnotation]
+	if id == "" {
+		return nil
+	}
+	if !usesArgoCDLogin(argoCDConfig) {
+		log.Info("Leaving the ArgoCD token behind, the spec no longer holds the credentials of ArgoCD", "token", id)
+		return nil
+	}
+
+	password, err := r.resolveCredential(ctx, geneziomanager.Namespace, credentialsFor(geneziomanager).argoCDPassword)
+	var specErr *specError
+	if errors.As(err, &specErr) || apierrors.IsNotFound(err) {
+		log.Info("Leaving the ArgoCD token behind, the password cannot be read", "token", id, "error", err.Error())
+		return nil
+	} else if err != nil {
+		return err
+	}
+	session, err := argoCDLogin(ctx, argoCDConfig, password)
+	if err == nil {
+		err = deleteArgoCDToken(ctx, argoCDConfig.URL, session, found)
+	}
+	if errors.Is(err, errArgoCDUnauthorized) {
+		log.Info("Leaving the ArgoCD token behind, ArgoCD rejected the credentials", "token", id)
+		r.recordWarning(geneziomanager, reasonTokenRevocationFailed, fmt.Sprintf(
+			"Left the ArgoCD token %s behind, ArgoCD rejected the credentials", id))
+		return nil
+	} else if err != nil {
+		log.Info("Leaving the ArgoCD token behind, it could not be revoked", "token", id, "error", err.Error())
+		r.recordWarning(geneziomanager, reasonTokenRevocationFailed, fmt.Sprintf(
+			"Left the ArgoCD token %s behind, it could not be revoked: %s", id, err))
+		return nil
+	}
+	log.Info("Revoked ArgoCD token", "token", id)
+	return nil
+}
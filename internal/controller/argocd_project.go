@@ -0,0 +1,192 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// appProjectGVK is the kind of the ArgoCD AppProject. It is handled as unstructured
+// so the operator does not depend on the Go types of ArgoCD.
+var appProjectGVK = schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "AppProject"}
+
+// inClusterServer is the API server URL ArgoCD uses for the cluster it runs in
+const inClusterServer = "https://kubernetes.default.svc"
+
+// argoCDProjectName returns the name of the AppProject of the GenezioManager
+func argoCDProjectName(geneziomanager *initv1alpha1.GenezioManager) string {
+	project := geneziomanager.Spec.ArgoCDConfig.AppProject
+	if project != nil && project.Name != "" {
+		return project.Name
+	}
+	return fmt.Sprintf("genezio-%s-%s", geneziomanager.Namespace, geneziomanager.Name)
+}
+
+// argoCDTokenProject returns the AppProject the project token of the genezio-manager
+// is generated for, which defaults to the AppProject managed by the operator
+func argoCDTokenProject(geneziomanager *initv1alpha1.GenezioManager) string {
+	if project := geneziomanager.Spec.ArgoCDConfig.Project; project != "" {
+		return project
+	}
+	return argoCDProjectName(geneziomanager)
+}
+
+// appProjectForGenezioManager returns the AppProject restricting the applications of
+// the genezio-manager to the deployment repository and the allowed destinations. It
+// holds the role of the project token when the token is generated for it.
+func appProjectForGenezioManager(geneziomanager *initv1alpha1.GenezioManager) (*unstructured.Unstructured, error) {
+	spec := geneziomanager.Spec
+	project := spec.ArgoCDConfig.AppProject
+
+	var repoURL string
+	switch {
+	case spec.GitConfig.SSH != nil:
+		repoURL = spec.GitConfig.SSH.CloneURL
+	case geneziomanager.Status.DeploymentRepository != nil:
+		repoURL = geneziomanager.Status.DeploymentRepository.CloneURL
+	}
+	if repoURL == "" {
+		return nil, fmt.Errorf("the clone URL of the deployment repository is not known yet")
+	}
+	sourceRepos := []interface{}{repoURL}
+	// The applications are rendered from the genezio charts
+	if spec.ChartRepo != "" {
+		sourceRepos = append(sourceRepos, spec.ChartRepo)
+	}
+
+	destinations := []interface{}{}
+	for _, destination := range project.Destinations {
+		server := destination.Server
+		if server == "" {
+			server = inClusterServer
+		}
+		destinations = append(destinations, map[string]interface{}{
+			"server":    server,
+			"namespace": destination.Namespace,
+		})
+	}
+	if len(destinations) == 0 {
+		destinations = append(destinations, map[string]interface{}{
+			"server":    inClusterServer,
+			"namespace": geneziomanager.Namespace,
+		})
+	}
+
+	clusterResources := []interface{}{}
+	for _, gk := range project.ClusterResourceWhitelist {
+		clusterResources = append(clusterResources, map[string]interface{}{"group": gk.Group, "kind": gk.Kind})
+	}
+
+	labels := map[string]interface{}{}
+	for k, v := range crossNamespaceLabelsForGenezioManager(geneziomanager, componentArgoCDProject) {
+		labels[k] = v
+	}
+
+	name := argoCDProjectName(geneziomanager)
+	appProjectSpec := map[string]interface{}{
+		"description": fmt.Sprintf("Applications of the GenezioManager %s/%s",
+			geneziomanager.Namespace, geneziomanager.Name),
+		"sourceRepos":              sourceRepos,
+		"destinations":             destinations,
+		"clusterResourceWhitelist": clusterResources,
+	}
+	// ArgoCD tracks the tokens of the role in the status of the AppProject, so
+	// applying the role again does not invalidate them
+	if role := spec.ArgoCDConfig.Role; spec.ArgoCDConfig.TokenType == "project" &&
+		argoCDTokenProject(geneziomanager) == name {
+		appProjectSpec["roles"] = []interface{}{map[string]interface{}{
+			"name":        role,
+			"description": "Role of the genezio-manager, its token is generated by the operator",
+			"policies": []interface{}{
+				fmt.Sprintf("p, proj:%s:%s, applications, *, %s/*, allow", name, role, name),
+			},
+		}}
+	}
+
+	appProject := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": argoCDNamespace(spec.ArgoCDConfig),
+			"labels":    labels,
+		},
+		"spec": appProjectSpec,
+	}}
+	// The type information is required by server-side apply
+	appProject.SetGroupVersionKind(appProjectGVK)
+	return appProject, nil
+}
+
+// reconcileArgoCDProject applies the AppProject of the GenezioManager when
+// spec.argocdConfig.appProject is set. Like the repository Secret, it lives in the
+// namespace of ArgoCD and is cleaned up here and by the finalizer.
+func (r *GenezioManagerReconciler) reconcileArgoCDProject(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) error {
+	log := log.FromContext(ctx)
+	if geneziomanager.Spec.ArgoCDConfig.AppProject == nil {
+		return r.deleteArgoCDProjects(ctx, geneziomanager, "")
+	}
+
+	appProject, err := appProjectForGenezioManager(geneziomanager)
+	if err != nil {
+		return err
+	}
+	if err := r.Patch(ctx, appProject, client.Apply, client.ForceOwnership, client.FieldOwner(fieldManager)); err != nil {
+		log.Error(err, "Failed to apply AppProject",
+			"AppProject.Namespace", appProject.GetNamespace(), "AppProject.Name", appProject.GetName())
+		return err
+	}
+	return r.deleteArgoCDProjects(ctx, geneziomanager, appProject.GetNamespace()+"/"+appProject.GetName())
+}
+
+// deleteArgoCDProjects deletes the AppProjects of the GenezioManager except the one to
+// keep, given as namespace/name. Nothing is deleted when ArgoCD is not installed.
+func (r *GenezioManagerReconciler) deleteArgoCDProjects(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager, keep string) error {
+	log := log.FromContext(ctx)
+	appProjects := &unstructured.UnstructuredList{}
+	appProjects.SetGroupVersionKind(appProjectGVK.GroupVersion().WithKind(appProjectGVK.Kind + "List"))
+	ls := crossNamespaceLabelsForGenezioManager(geneziomanager, componentArgoCDProject)
+	err := r.List(ctx, appProjects, client.MatchingLabels(ls))
+	// Discovery reports a missing API group as not found rather than as a missing kind
+	if meta.IsNoMatchError(err) || apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for i := range appProjects.Items {
+		appProject := &appProjects.Items[i]
+		if appProject.GetNamespace()+"/"+appProject.GetName() == keep {
+			continue
+		}
+		log.Info("Deleting AppProject", "AppProject.Namespace", appProject.GetNamespace(),
+			"AppProject.Name", appProject.GetName())
+		if err := r.Delete(ctx, appProject); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
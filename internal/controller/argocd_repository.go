@@ -0,0 +1,230 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// defaultArgoCDNamespace is the namespace ArgoCD is installed in by default
+const defaultArgoCDNamespace = "argocd"
+
+// argoCDSecretTypeLabel tells ArgoCD which kind of declarative Secret it is looking at
+const argoCDSecretTypeLabel = "argocd.argoproj.io/secret-type"
+
+// Values of argoCDSecretTypeLabel supported by the operator
+const (
+	argoCDSecretTypeRepository = "repository"
+	argoCDSecretTypeRepoCreds  = "repo-creds"
+)
+
+// ownerNamespaceLabel records the namespace of the GenezioManager on objects living
+// in other namespaces, which cannot carry an owner reference
+const ownerNamespaceLabel = "init.genezio.com/owner-namespace"
+
+// componentLabel tells apart the objects of a GenezioManager living in other namespaces
+const componentLabel = "app.kubernetes.io/component"
+
+// Values of componentLabel for the objects living in the namespace of ArgoCD
+const (
+	componentArgoCDRepository = "argocd-repository"
+	componentArgoCDProject    = "argocd-project"
+)
+
+// argoCDRepositorySecretName returns the name of the repository Secret of a
+// GenezioManager, unique across the namespaces sharing the ArgoCD instance
+func argoCDRepositorySecretName(geneziomanager *initv1alpha1.GenezioManager) string {
+	return fmt.Sprintf("genezio-%s-%s-repository", geneziomanager.Namespace, geneziomanager.Name)
+}
+
+// crossNamespaceLabelsForGenezioManager returns the labels selecting the objects of a
+// component of a GenezioManager living in other namespaces, e.g. the namespace of ArgoCD
+func crossNamespaceLabelsForGenezioManager(geneziomanager *initv1alpha1.GenezioManager,
+	component string) map[string]string {
+	ls := selectorLabelsForGenezioManager(geneziomanager.Name)
+	ls[ownerNamespaceLabel] = geneziomanager.Namespace
+	ls[componentLabel] = component
+	return ls
+}
+
+// argoCDRepositoryData returns the URL and credentials of the deployment repository
+// in the format of a declarative ArgoCD repository Secret
+func (r *GenezioManagerReconciler) argoCDRepositoryData(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) (map[string][]byte, error) {
+	gitConfig := geneziomanager.Spec.GitConfig
+	creds := credentialsFor(geneziomanager)
+	data := map[string][]byte{"type": []byte("git")}
+
+	var repoURL string
+	if gitConfig.SSH != nil {
+		repoURL = gitConfig.SSH.CloneURL
+		key, err := r.resolveCredential(ctx, geneziomanager.Namespace, creds.sshPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		data["sshPrivateKey"] = []byte(key)
+	} else {
+		if geneziomanager.Status.DeploymentRepository == nil || geneziomanager.Status.DeploymentRepository.CloneURL == "" {
+			return nil, fmt.Errorf("the clone URL of the deployment repository is not known yet")
+		}
+		repoURL = geneziomanager.Status.DeploymentRepository.CloneURL
+
+		password, err := r.resolveGitPassword(ctx, geneziomanager)
+		if err != nil {
+			return nil, err
+		}
+		data["username"] = []byte(creds.git.username)
+		data["password"] = []byte(password)
+	}
+
+	if argoCDRepositorySecretType(geneziomanager.Spec.ArgoCDConfig) == argoCDSecretTypeRepoCreds {
+		// The credential template matches every repository of the owner of the deployment repository
+		prefix, err := argoCDRepoCredsURL(repoURL)
+		if err != nil {
+			return nil, err
+		}
+		data["url"] = []byte(prefix)
+	} else {
+		data["url"] = []byte(repoURL)
+		data["name"] = []byte(gitConfig.DeployementRepoName)
+	}
+	return data, nil
+}
+
+// errNoRepoCredsURL is returned when the URL of the deployment repository has no
+// owner whose repositories a credential template could match
+var errNoRepoCredsURL = errors.New("no credential template URL can be derived")
+
+// argoCDRepoCredsURL returns the URL prefix matching the repositories of the owner of
+// the deployment repository, for both URLs such as https://host/owner/repo.git and
+// the scp-like syntax git@host:owner/repo.git
+func argoCDRepoCredsURL(repoURL string) (string, error) {
+	if strings.Contains(repoURL, "://") {
+		u, err := url.Parse(repoURL)
+		if err != nil || u.Host == "" {
+			return "", fmt.Errorf("%w from the repository URL %q", errNoRepoCredsURL, repoURL)
+		}
+		owner := strings.LastIndex(strings.TrimSuffix(u.Path, "/"), "/")
+		if owner <= 0 {
+			return "", fmt.Errorf("%w from the repository URL %q, it has no owner", errNoRepoCredsURL, repoURL)
+		}
+		u.Path, u.RawPath, u.RawQuery, u.Fragment = u.Path[:owner+1], "", "", ""
+		return u.String(), nil
+	}
+
+	host, path, found := strings.Cut(repoURL, ":")
+	owner := strings.LastIndex(strings.TrimSuffix(path, "/"), "/")
+	if !found || host == "" || owner <= 0 {
+		return "", fmt.Errorf("%w from the repository URL %q, it has no owner", errNoRepoCredsURL, repoURL)
+	}
+	return host + ":" + path[:owner+1], nil
+}
+
+func argoCDRepositorySecretType(argoCDConfig initv1alpha1.ArgoCDConfig) string {
+	if argoCDConfig.Repository != nil && argoCDConfig.Repository.SecretType != "" {
+		return argoCDConfig.Repository.SecretType
+	}
+	return argoCDSecretTypeRepository
+}
+
+func argoCDNamespace(argoCDConfig initv1alpha1.ArgoCDConfig) string {
+	if argoCDConfig.Namespace != "" {
+		return argoCDConfig.Namespace
+	}
+	return defaultArgoCDNamespace
+}
+
+// reconcileArgoCDRepository applies the Secret declaring the deployment repository to
+// ArgoCD when spec.argocdConfig.repository is set. The Secret lives in the namespace
+// of ArgoCD, so it cannot be garbage collected through an owner reference: Secrets
+// left behind by a previous configuration are deleted here and by the finalizer.
+func (r *GenezioManagerReconciler) reconcileArgoCDRepository(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) error {
+	log := log.FromContext(ctx)
+	argoCDConfig := geneziomanager.Spec.ArgoCDConfig
+	if argoCDConfig.Repository == nil {
+		return r.deleteArgoCDRepositorySecrets(ctx, geneziomanager, "")
+	}
+
+	data, err := r.argoCDRepositoryData(ctx, geneziomanager)
+	if errors.Is(err, errNoRepoCredsURL) {
+		// The credential template is skipped rather than matching the wrong repositories
+		log.Info("Skipping the ArgoCD credential template", "reason", err.Error())
+		r.recordWarning(geneziomanager, reasonInvalidArgoCDConfig,
+			fmt.Sprintf("Skipped the ArgoCD credential template: %s", err))
+		return r.deleteArgoCDRepositorySecrets(ctx, geneziomanager, "")
+	} else if err != nil {
+		return err
+	}
+
+	ls := crossNamespaceLabelsForGenezioManager(geneziomanager, componentArgoCDRepository)
+	ls[argoCDSecretTypeLabel] = argoCDRepositorySecretType(argoCDConfig)
+	secret := &corev1.Secret{
+		// The type information is required by server-side apply
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      argoCDRepositorySecretName(geneziomanager),
+			Namespace: argoCDNamespace(argoCDConfig),
+			Labels:    ls,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+	if err := r.Patch(ctx, secret, client.Apply, client.ForceOwnership, client.FieldOwner(fieldManager)); err != nil {
+		log.Error(err, "Failed to apply Secret", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
+		return err
+	}
+	return r.deleteArgoCDRepositorySecrets(ctx, geneziomanager, secret.Namespace)
+}
+
+// deleteArgoCDRepositorySecrets deletes the repository Secrets of the GenezioManager,
+// except the one living in the namespace to keep, if any
+func (r *GenezioManagerReconciler) deleteArgoCDRepositorySecrets(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager, keepNamespace string) error {
+	log := log.FromContext(ctx)
+	secrets := &corev1.SecretList{}
+	ls := crossNamespaceLabelsForGenezioManager(geneziomanager, componentArgoCDRepository)
+	if err := r.List(ctx, secrets, client.MatchingLabels(ls)); err != nil {
+		return err
+	}
+
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if secret.Namespace == keepNamespace && secret.Name == argoCDRepositorySecretName(geneziomanager) {
+			continue
+		}
+		log.Info("Deleting ArgoCD repository Secret", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
+		if err := r.Delete(ctx, secret); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
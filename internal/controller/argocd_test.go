@@ -0,0 +1,300 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeJWT returns an unsigned JWT carrying the given expiration time
+func fakeJWT(exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp.Unix())))
+	return "e30." + payload + ".c2lnbmF0dXJl"
+}
+
+// newArgoCDServer returns an httptest stand-in of the session and token endpoints of ArgoCD
+func newArgoCDServer(sessionExp time.Time) *httptest.Server {
+	session := fakeJWT(sessionExp)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/session" {
+			login := map[string]string{}
+			_ = json.NewDecoder(r.Body).Decode(&login)
+			if login["username"] != "admin" || login["password"] != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"token":"` + session + `"}`))
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer "+session {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/account/genezio/token", "/api/v1/projects/genezio/roles/manager/token":
+			request := struct {
+				ExpiresIn int64 `json:"expiresIn"`
+			}{}
+			_ = json.NewDecoder(r.Body).Decode(&request)
+			_, _ = w.Write([]byte(`{"token":"` + r.URL.Path + fmt.Sprintf("/%d", request.ExpiresIn) + `"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestGenerateArgoCDToken(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	sessionExp := now.Add(12 * time.Hour)
+	server := newArgoCDServer(sessionExp)
+	defer server.Close()
+
+	ctx := context.Background()
+	argoCDConfig := initv1alpha1.ArgoCDConfig{URL: server.URL, Username: "admin"}
+
+	if _, err := argoCDLogin(ctx, argoCDConfig, "wrong"); !errors.Is(err, errArgoCDUnauthorized) {
+		t.Fatalf("expected errArgoCDUnauthorized, got %v", err)
+	}
+	session, err := argoCDLogin(ctx, argoCDConfig, "secret")
+	if err != nil {
+		t.Fatalf("unexpected error logging in: %v", err)
+	}
+	token, err := generateArgoCDToken(ctx, argoCDConfig, session, now)
+	if err != nil {
+		t.Fatalf("unexpected error generating a session token: %v", err)
+	}
+	if token.token != fakeJWT(sessionExp) || token.id != "" || !token.expiresAt.Equal(sessionExp) {
+		t.Fatalf("unexpected session token %+v", token)
+	}
+
+	argoCDConfig.TokenType = "account"
+	argoCDConfig.Account = "genezio"
+	argoCDConfig.TokenTTL = &metav1.Duration{Duration: time.Hour}
+	token, err = generateArgoCDToken(ctx, argoCDConfig, session, now)
+	if err != nil {
+		t.Fatalf("unexpected error generating an account token: %v", err)
+	}
+	if token.token != "/api/v1/account/genezio/token/3600" || token.id != fmt.Sprintf("genezio-operator-%d", now.Unix()) ||
+		!token.expiresAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("unexpected account token %+v", token)
+	}
+
+	argoCDConfig.TokenType = "project"
+	argoCDConfig.Project = "genezio"
+	argoCDConfig.Role = "manager"
+	argoCDConfig.TokenTTL = nil
+	token, err = generateArgoCDToken(ctx, argoCDConfig, session, now)
+	if err != nil {
+		t.Fatalf("unexpected error generating a project token: %v", err)
+	}
+	if token.token != "/api/v1/projects/genezio/roles/manager/token/86400" {
+		t.Fatalf("unexpected project token %+v", token)
+	}
+
+	if _, err := generateArgoCDToken(ctx, argoCDConfig, "expired", now); !errors.Is(err, errArgoCDUnauthorized) {
+		t.Fatalf("expected errArgoCDUnauthorized for an invalid session, got %v", err)
+	}
+}
+
+func TestDeleteArgoCDToken(t *testing.T) {
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.Header.Get("Authorization") != "Bearer session" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		deleted = append(deleted, r.URL.RequestURI())
+		if strings.HasSuffix(r.URL.Path, "/gone") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	secret := func(source, id, token string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				argoCDTokenSourceAnnotation: source,
+				argoCDTokenIDAnnotation:     id,
+			}},
+			Data: map[string][]byte{argoCDTokenSecretKey: []byte(token)},
+		}
+	}
+	issued := base64.RawURLEncoding.EncodeToString([]byte(`{"iat":1700000000}`))
+	for _, s := range []*corev1.Secret{
+		secret("account/genezio", "genezio-operator-1", ""),
+		secret("project/genezio/manager", "genezio-operator-2", "e30."+issued+".c2lnbmF0dXJl"),
+		secret("account/genezio", "gone", ""),
+		// Session tokens have no id and are not revoked
+		secret("session/admin", "", "session-token"),
+	} {
+		if err := deleteArgoCDToken(ctx, server.URL, "session", s); err != nil {
+			t.Fatalf("unexpected error deleting %v: %v", s.Annotations, err)
+		}
+	}
+
+	expected := []string{
+		"/api/v1/account/genezio/token/genezio-operator-1",
+		"/api/v1/projects/genezio/roles/manager/token/1700000000?id=genezio-operator-2",
+		"/api/v1/account/genezio/token/gone",
+	}
+	if strings.Join(deleted, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected the requests %v, got %v", expected, deleted)
+	}
+	if err := deleteArgoCDToken(ctx, server.URL, "expired", secret("account/genezio", "genezio-operator-1", "")); !errors.Is(err, errArgoCDUnauthorized) {
+		t.Fatalf("expected errArgoCDUnauthorized, got %v", err)
+	}
+}
+
+func TestValidateArgoCDConfig(t *testing.T) {
+	argoCDConfig := initv1alpha1.ArgoCDConfig{URL: "https://argocd.example.com", Password: "token"}
+	if err := validateArgoCDConfig(argoCDConfig); err != nil {
+		t.Fatalf("expected a pass-through token to be accepted, got %v", err)
+	}
+
+	argoCDConfig.Username = "admin"
+	argoCDConfig.TokenType = "project"
+	var specErr *specError
+	if err := validateArgoCDConfig(argoCDConfig); !errors.As(err, &specErr) || specErr.Reason != reasonInvalidArgoCDConfig {
+		t.Fatalf("expected a project token without a role to be rejected, got %v", err)
+	}
+}
+
+func TestArgoCDTokenRefreshDelay(t *testing.T) {
+	now := time.Now()
+	geneziomanager := &initv1alpha1.GenezioManager{
+		Spec: initv1alpha1.GenezioManagerSpec{ArgoCDConfig: initv1alpha1.ArgoCDConfig{Username: "admin"}},
+	}
+	if delay := argoCDTokenRefreshDelay(geneziomanager, now); delay != 0 {
+		t.Fatalf("expected no delay before a token is generated, got %v", delay)
+	}
+
+	geneziomanager.Status.ArgoCDTokenExpirationTime = &metav1.Time{Time: now.Add(6 * time.Hour)}
+	if delay := argoCDTokenRefreshDelay(geneziomanager, now); delay != 3*time.Hour {
+		t.Fatalf("expected to check the token again halfway to its expiration, got %v", delay)
+	}
+}
+
+func TestAppProjectForGenezioManager(t *testing.T) {
+	geneziomanager := &initv1alpha1.GenezioManager{
+		ObjectMeta: metav1.ObjectMeta{Name: "manager", Namespace: "tenant-a"},
+		Spec: initv1alpha1.GenezioManagerSpec{
+			ChartRepo: "https://charts.genez.io",
+			ArgoCDConfig: initv1alpha1.ArgoCDConfig{
+				AppProject: &initv1alpha1.ArgoCDProjectConfig{
+					ClusterResourceWhitelist: []metav1.GroupKind{{Group: "", Kind: "Namespace"}},
+				},
+			},
+		},
+	}
+	if _, err := appProjectForGenezioManager(geneziomanager); err == nil {
+		t.Fatalf("expected an error before the clone URL of the deployment repository is known")
+	}
+
+	geneziomanager.Status.DeploymentRepository = &initv1alpha1.DeploymentRepositoryStatus{
+		CloneURL: "https://gitea.example.com/genezio/deployments.git",
+	}
+	appProject, err := appProjectForGenezioManager(geneziomanager)
+	if err != nil {
+		t.Fatalf("unexpected error rendering the AppProject: %v", err)
+	}
+	if appProject.GetName() != "genezio-tenant-a-manager" || appProject.GetNamespace() != defaultArgoCDNamespace {
+		t.Fatalf("unexpected AppProject %s/%s", appProject.GetNamespace(), appProject.GetName())
+	}
+
+	spec := appProject.Object["spec"].(map[string]interface{})
+	sourceRepos := spec["sourceRepos"].([]interface{})
+	if len(sourceRepos) != 2 || sourceRepos[0] != "https://gitea.example.com/genezio/deployments.git" {
+		t.Fatalf("unexpected source repositories %v", sourceRepos)
+	}
+	destinations := spec["destinations"].([]interface{})
+	if len(destinations) != 1 || destinations[0].(map[string]interface{})["namespace"] != "tenant-a" {
+		t.Fatalf("expected the namespace of the GenezioManager as the only destination, got %v", destinations)
+	}
+	if whitelist := spec["clusterResourceWhitelist"].([]interface{}); len(whitelist) != 1 {
+		t.Fatalf("unexpected cluster resource whitelist %v", whitelist)
+	}
+	if _, ok := spec["roles"]; ok {
+		t.Fatalf("expected no roles without a project token, got %v", spec["roles"])
+	}
+
+	// Project tokens default to a role of the managed AppProject
+	geneziomanager.Spec.ArgoCDConfig.TokenType = "project"
+	geneziomanager.Spec.ArgoCDConfig.Role = "deployer"
+	appProject, err = appProjectForGenezioManager(geneziomanager)
+	if err != nil {
+		t.Fatalf("unexpected error rendering the AppProject: %v", err)
+	}
+	roles, _ := appProject.Object["spec"].(map[string]interface{})["roles"].([]interface{})
+	if len(roles) != 1 {
+		t.Fatalf("expected the role of the project token, got %v", roles)
+	}
+	role := roles[0].(map[string]interface{})
+	policies := role["policies"].([]interface{})
+	if role["name"] != "deployer" || len(policies) != 1 || policies[0] !=
+		"p, proj:genezio-tenant-a-manager:deployer, applications, *, genezio-tenant-a-manager/*, allow" {
+		t.Fatalf("unexpected role %v", role)
+	}
+
+	// Tokens of another AppProject leave the managed one without roles
+	geneziomanager.Spec.ArgoCDConfig.Project = "shared"
+	appProject, err = appProjectForGenezioManager(geneziomanager)
+	if err != nil {
+		t.Fatalf("unexpected error rendering the AppProject: %v", err)
+	}
+	if _, ok := appProject.Object["spec"].(map[string]interface{})["roles"]; ok {
+		t.Fatalf("expected no roles for the token of another AppProject")
+	}
+}
+
+func TestArgoCDRepoCredsURL(t *testing.T) {
+	for repoURL, prefix := range map[string]string{
+		"https://gitea.example.com/genezio/deployments.git":     "https://gitea.example.com/genezio/",
+		"https://gitlab.example.com/platform/apps/deployments":  "https://gitlab.example.com/platform/apps/",
+		"ssh://git@gitea.example.com:2222/genezio/deployments":  "ssh://git@gitea.example.com:2222/genezio/",
+		"git@github.com:genezio/deployments.git":                "git@github.com:genezio/",
+		"git@gitlab.example.com:platform/apps/deployments.git/": "git@gitlab.example.com:platform/apps/",
+	} {
+		if got, err := argoCDRepoCredsURL(repoURL); err != nil || got != prefix {
+			t.Errorf("%s: expected the prefix %q, got %q, %v", repoURL, prefix, got, err)
+		}
+	}
+	for _, repoURL := range []string{
+		"git@gitea.example.com:deployments.git",
+		"https://gitea.example.com/deployments.git",
+		"ssh://gitea.example.com",
+		"deployments",
+	} {
+		if got, err := argoCDRepoCredsURL(repoURL); !errors.Is(err, errNoRepoCredsURL) {
+			t.Errorf("%s: expected errNoRepoCredsURL, got %q, %v", repoURL, got, err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Reasons used on the Degraded condition when the spec cannot be reconciled
+const (
+	reasonInvalidCredentials = "InvalidCredentials"
+	reasonSecretNotFound     = "SecretNotFound"
+	reasonSecretKeyNotFound  = "SecretKeyNotFound"
+)
+
+// specError is returned when the GenezioManager spec, or an object it references,
+// prevents the reconciliation from moving forward. It is surfaced as a status
+// condition instead of being retried as a transient error.
+type specError struct {
+	Reason  string
+	Message string
+}
+
+func (e *specError) Error() string {
+	return e.Message
+}
+
+// specErrorFor reports the problems found by the validation shared with the webhook
+// as a specError with the given reason, nil when there are none
+func specErrorFor(reason string, errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &specError{Reason: reason, Message: errs.ToAggregate().Error()}
+}
+
+// credential is a value which can be given either inline in the spec or as a
+// reference to a key of a Secret living in the namespace of the GenezioManager.
+type credential struct {
+	// path is the field path of the literal value, used in error messages
+	path       string
+	value      string
+	secretName string
+	secretKey  string
+}
+
+// envVar renders the credential as an environment variable of the operand. Secret
+// references are passed through as a SecretKeyRef so the value is never copied
+// into the Deployment.
+func (c credential) envVar(name string) corev1.EnvVar {
+	if c.secretName == "" {
+		return corev1.EnvVar{Name: name, Value: c.value}
+	}
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: c.secretName},
+				Key:                  c.secretKey,
+			},
+		},
+	}
+}
+
+// credentialsForGenezioManager holds every credential of a GenezioManager spec
+type credentialsForGenezioManager struct {
+	git              gitCredentials
+	sshPrivateKey    credential
+	sshKnownHosts    credential
+	argoCDPassword   credential
+	registryPassword credential
+}
+
+func credentialsFor(geneziomanager *initv1alpha1.GenezioManager) credentialsForGenezioManager {
+	spec := geneziomanager.Spec
+	creds := credentialsForGenezioManager{
+		argoCDPassword: credential{
+			path:       "spec.argocdConfig.password",
+			value:      spec.ArgoCDConfig.Password,
+			secretName: spec.ArgoCDConfig.PasswordSecretName,
+			secretKey:  spec.ArgoCDConfig.PasswordSecretKey,
+		},
+		registryPassword: credential{
+			path:       "spec.containerRegistryConfig.password",
+			value:      spec.ContainerRegistryConfig.Password,
+			secretName: spec.ContainerRegistryConfig.PasswordSecretName,
+			secretKey:  spec.ContainerRegistryConfig.PasswordSecretKey,
+		},
+	}
+
+	// Unknown providers are reported by validateGitConfig
+	if provider, err := gitProviderFor(spec.GitConfig); err == nil {
+		creds.git = provider.Credentials(spec.GitConfig)
+	}
+	creds.sshPrivateKey, creds.sshKnownHosts = sshCredentialsFor(spec.GitConfig.SSH)
+	return creds
+}
+
+func (c credentialsForGenezioManager) all() []credential {
+	return []credential{c.git.password, c.git.token, c.git.ca, c.sshPrivateKey, c.sshKnownHosts,
+		c.argoCDPassword, c.registryPassword}
+}
+
+// validateCredentials checks that no credential sets both a literal value and a Secret reference
+func validateCredentials(spec initv1alpha1.GenezioManagerSpec) error {
+	return specErrorFor(reasonInvalidCredentials, initv1alpha1.ValidateCredentials(spec, field.NewPath("spec")))
+}
+
+// checkCredentials validates the credentials of the GenezioManager and ensures that
+// every referenced Secret exists and holds the referenced key.
+func (r *GenezioManagerReconciler) checkCredentials(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) error {
+	if err := validateCredentials(geneziomanager.Spec); err != nil {
+		return err
+	}
+	creds := credentialsFor(geneziomanager)
+
+	for _, cred := range creds.all() {
+		if cred.secretName == "" {
+			continue
+		}
+
+		secret := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: cred.secretName, Namespace: geneziomanager.Namespace}, secret)
+		if err != nil && apierrors.IsNotFound(err) {
+			return &specError{Reason: reasonSecretNotFound,
+				Message: fmt.Sprintf("Secret %s referenced by %sSecretName not found", cred.secretName, cred.path)}
+		} else if err != nil {
+			return err
+		}
+
+		if _, ok := secret.Data[cred.secretKey]; !ok {
+			return &specError{Reason: reasonSecretKeyNotFound,
+				Message: fmt.Sprintf("key %s not found in Secret %s referenced by %sSecretKey",
+					cred.secretKey, cred.secretName, cred.path)}
+		}
+	}
+	return nil
+}
+
+// resolveCredential returns the value of a credential, reading it from the
+// referenced Secret if needed
+func (r *GenezioManagerReconciler) resolveCredential(ctx context.Context, namespace string,
+	cred credential) (string, error) {
+	if cred.secretName == "" {
+		return cred.value, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: cred.secretName, Namespace: namespace}, secret); err != nil {
+		return "", err
+	}
+	value, ok := secret.Data[cred.secretKey]
+	if !ok {
+		return "", &specError{Reason: reasonSecretKeyNotFound,
+			Message: fmt.Sprintf("key %s not found in Secret %s referenced by %sSecretKey",
+				cred.secretKey, cred.secretName, cred.path)}
+	}
+	return string(value), nil
+}
+
+// gitAuthFor resolves the credentials used by the operator to call the API of the git provider
+func (r *GenezioManagerReconciler) gitAuthFor(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) (gitAuth, error) {
+	creds := credentialsFor(geneziomanager).git
+	auth := gitAuth{username: creds.username}
+
+	var err error
+	if auth.password, err = r.resolveCredential(ctx, geneziomanager.Namespace, creds.password); err != nil {
+		return gitAuth{}, err
+	}
+	if auth.token, err = r.resolveCredential(ctx, geneziomanager.Namespace, creds.token); err != nil {
+		return gitAuth{}, err
+	}
+	ca, err := r.resolveCredential(ctx, geneziomanager.Namespace, creds.ca)
+	if err != nil {
+		return gitAuth{}, err
+	}
+	auth.caBundle = []byte(ca)
+	return auth, nil
+}
+
+// resolveGitPassword returns the secret used by CD engines to pull the deployment
+// repository over HTTPS: the token if there is one, like the genezio-manager, or
+// the password otherwise
+func (r *GenezioManagerReconciler) resolveGitPassword(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) (string, error) {
+	creds := credentialsFor(geneziomanager).git
+	password := creds.token
+	if usesMintedGiteaToken(geneziomanager.Spec.GitConfig) {
+		password = mintedGiteaTokenCredential(geneziomanager.Name)
+	} else if password.value == "" && password.secretName == "" {
+		password = creds.password
+	}
+	return r.resolveCredential(ctx, geneziomanager.Namespace, password)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// expectDegraded checks that err is the specError with the given reason and that it
+// turns the GenezioManager Degraded and unavailable, as Reconcile reports it
+func expectDegraded(t *testing.T, geneziomanager *initv1alpha1.GenezioManager, err error, reason string) {
+	t.Helper()
+	var specErr *specError
+	if !errors.As(err, &specErr) || specErr.Reason != reason {
+		t.Fatalf("expected an %s error, got %v", reason, err)
+	}
+
+	setSpecErrorConditions(geneziomanager, specErr)
+	degraded := meta.FindStatusCondition(geneziomanager.Status.Conditions, typeDegradedGenezioManager)
+	if degraded == nil || degraded.Status != metav1.ConditionTrue || degraded.Reason != reason {
+		t.Fatalf("expected Degraded with reason %s, got %v", reason, degraded)
+	}
+	available := meta.FindStatusCondition(geneziomanager.Status.Conditions, typeAvailableGenezioManager)
+	if available == nil || available.Status != metav1.ConditionFalse {
+		t.Fatalf("expected Available to be false, got %v", available)
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	// Inline credentials are checked without reading any Secret
+	r := &GenezioManagerReconciler{}
+	geneziomanager := &initv1alpha1.GenezioManager{
+		ObjectMeta: metav1.ObjectMeta{Name: "manager", Namespace: "default"},
+		Spec: initv1alpha1.GenezioManagerSpec{
+			GitConfig: initv1alpha1.GitConfig{
+				Provider:            "gitea",
+				DeployementRepoName: "deployments",
+				Gitea: initv1alpha1.GiteaProvider{
+					URL:      "https://gitea.example.com",
+					Username: "genezio",
+					Token:    "gitea-token",
+				},
+			},
+			ContainerRegistryConfig: initv1alpha1.ContainerRegistryConfig{
+				URL:      "registry.example.com",
+				Username: "genezio",
+				Password: "plaintext",
+			},
+		},
+	}
+	if err := r.checkCredentials(context.Background(), geneziomanager); err != nil {
+		t.Fatalf("unexpected error for inline credentials: %v", err)
+	}
+
+	geneziomanager.Spec.ContainerRegistryConfig.PasswordSecretName = "registry"
+	geneziomanager.Spec.ContainerRegistryConfig.PasswordSecretKey = "password"
+	expectDegraded(t, geneziomanager, r.checkCredentials(context.Background(), geneziomanager),
+		reasonInvalidCredentials)
+	expectDegraded(t, geneziomanager, r.checkSpec(context.Background(), geneziomanager), reasonInvalidCredentials)
+}
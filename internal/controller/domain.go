@@ -0,0 +1,75 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// defaultDomain is the domain of the applications when the spec does not set one
+const defaultDomain = "local"
+
+// reasonInvalidDomain is used on the Degraded condition when spec.domain is invalid
+const reasonInvalidDomain = "InvalidDomain"
+
+// domainForGenezioManager returns the domain the applications are published under
+func domainForGenezioManager(spec initv1alpha1.GenezioManagerSpec) string {
+	if spec.Domain != "" {
+		return spec.Domain
+	}
+	return defaultDomain
+}
+
+// validateDomain ensures that spec.domain is a DNS subdomain. The CRD enforces it
+// too, this covers objects stored before the validation was added.
+func validateDomain(spec initv1alpha1.GenezioManagerSpec) error {
+	return specErrorFor(reasonInvalidDomain, initv1alpha1.ValidateDomain(spec.Domain, field.NewPath("spec", "domain")))
+}
+
+// baseURLForGenezioManager returns the URL the applications are published under.
+// They are served over HTTPS when a wildcard certificate is given.
+func baseURLForGenezioManager(spec initv1alpha1.GenezioManagerSpec) string {
+	scheme := "http"
+	if spec.WildcardTLSSecretName != "" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, domainForGenezioManager(spec))
+}
+
+// domainEnvForGenezioManager renders the optional certificate and ingress class the
+// applications are published with, next to the DOMAIN variable. Like any change of
+// the environment, a change of the domain rolls the Deployment.
+func domainEnvForGenezioManager(spec initv1alpha1.GenezioManagerSpec) []corev1.EnvVar {
+	var env []corev1.EnvVar
+	if spec.WildcardTLSSecretName != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  "WILDCARD_TLS_SECRET_NAME",
+			Value: spec.WildcardTLSSecretName,
+		})
+	}
+	if spec.AppsIngressClassName != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  "INGRESS_CLASS_NAME",
+			Value: spec.AppsIngressClassName,
+		})
+	}
+	return env
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+)
+
+func TestValidateDomain(t *testing.T) {
+	for _, domain := range []string{"", "local", "apps.example.com"} {
+		if err := validateDomain(initv1alpha1.GenezioManagerSpec{Domain: domain}); err != nil {
+			t.Errorf("unexpected error for domain %q: %v", domain, err)
+		}
+	}
+	for _, domain := range []string{"Apps.example.com", "apps..example.com", "*.example.com", "-apps.example.com"} {
+		var specErr *specError
+		if err := validateDomain(initv1alpha1.GenezioManagerSpec{Domain: domain}); !errors.As(err, &specErr) ||
+			specErr.Reason != reasonInvalidDomain {
+			t.Errorf("expected an %s error for domain %q, got %v", reasonInvalidDomain, domain, err)
+		}
+	}
+}
+
+func TestBaseURLForGenezioManager(t *testing.T) {
+	if url := baseURLForGenezioManager(initv1alpha1.GenezioManagerSpec{}); url != "http://local" {
+		t.Errorf("expected the default base URL http://local, got %s", url)
+	}
+	spec := initv1alpha1.GenezioManagerSpec{Domain: "apps.example.com", WildcardTLSSecretName: "wildcard-tls"}
+	if url := baseURLForGenezioManager(spec); url != "https://apps.example.com" {
+		t.Errorf("expected https://apps.example.com with a wildcard certificate, got %s", url)
+	}
+}
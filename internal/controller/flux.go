@@ -0,0 +1,328 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// reasonInvalidCDConfig is used on the Degraded condition when the CD engine is misconfigured
+const reasonInvalidCDConfig = "InvalidCDConfig"
+
+// CD engines supported by spec.cd.engine
+const (
+	cdEngineArgoCD = "argocd"
+	cdEngineFlux   = "flux"
+)
+
+// Kinds of the Flux object applying the deployment repository
+const (
+	fluxKindKustomization = "Kustomization"
+	fluxKindHelmRelease   = "HelmRelease"
+)
+
+// defaultFluxPath and defaultFluxInterval are the defaults of spec.cd.flux
+const (
+	defaultFluxPath     = "./apps"
+	defaultFluxInterval = time.Minute
+)
+
+// The Flux objects are handled as unstructured so the operator does not depend on
+// the Go types of Flux, nor on Flux being installed when the argocd engine is used.
+var (
+	fluxGitRepositoryGVK = schema.GroupVersionKind{Group: "source.toolkit.fluxcd.io", Version: "v1",
+		Kind: "GitRepository"}
+	fluxKustomizationGVK = schema.GroupVersionKind{Group: "kustomize.toolkit.fluxcd.io", Version: "v1",
+		Kind: fluxKindKustomization}
+	fluxHelmReleaseGVK = schema.GroupVersionKind{Group: "helm.toolkit.fluxcd.io", Version: "v2beta2",
+		Kind: fluxKindHelmRelease}
+)
+
+// cdEngine returns the CD engine selected by the spec
+func cdEngine(spec initv1alpha1.GenezioManagerSpec) string {
+	if spec.CD.Engine != "" {
+		return spec.CD.Engine
+	}
+	return cdEngineArgoCD
+}
+
+// validateCDConfig ensures that only the configuration block of the selected engine is set
+func validateCDConfig(spec initv1alpha1.GenezioManagerSpec) error {
+	return specErrorFor(reasonInvalidCDConfig, initv1alpha1.ValidateCDConfig(spec, field.NewPath("spec")))
+}
+
+// fluxGitSecretName returns the name of the Secret Flux pulls the deployment repository with
+func fluxGitSecretName(name string) string {
+	return name + "-flux-git"
+}
+
+func fluxKind(flux *initv1alpha1.FluxConfig) string {
+	if flux.Kind != "" {
+		return flux.Kind
+	}
+	return fluxKindKustomization
+}
+
+func fluxPath(flux *initv1alpha1.FluxConfig) string {
+	if flux.Path != "" {
+		return flux.Path
+	}
+	return defaultFluxPath
+}
+
+func fluxInterval(flux *initv1alpha1.FluxConfig) string {
+	if flux.Interval != nil && flux.Interval.Duration > 0 {
+		return flux.Interval.Duration.String()
+	}
+	return defaultFluxInterval.String()
+}
+
+// fluxEnvForGenezioManager returns the environment telling the genezio-manager
+// where the Flux objects live
+func fluxEnvForGenezioManager(geneziomanager *initv1alpha1.GenezioManager) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{
+			Name:  "FLUX_NAMESPACE",
+			Value: geneziomanager.Namespace,
+		},
+		{
+			Name:  "FLUX_SOURCE_NAME",
+			Value: geneziomanager.Name,
+		},
+		{
+			Name:  "FLUX_KIND",
+			Value: fluxKind(geneziomanager.Spec.CD.Flux),
+		},
+	}
+}
+
+// fluxGitSecretData returns the credentials of the deployment repository in the
+// format expected by the secretRef of a Flux GitRepository
+func (r *GenezioManagerReconciler) fluxGitSecretData(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) (map[string][]byte, error) {
+	creds := credentialsFor(geneziomanager)
+	if geneziomanager.Spec.GitConfig.SSH != nil {
+		identity, err := r.resolveCredential(ctx, geneziomanager.Namespace, creds.sshPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		knownHosts, err := r.resolveCredential(ctx, geneziomanager.Namespace, creds.sshKnownHosts)
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{"identity": []byte(identity), "known_hosts": []byte(knownHosts)}, nil
+	}
+
+	password, err := r.resolveGitPassword(ctx, geneziomanager)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{"username": []byte(creds.git.username), "password": []byte(password)}, nil
+}
+
+// fluxObjectsForGenezioManager returns the GitRepository pulling the deployment
+// repository and the Kustomization or HelmRelease applying it
+func fluxObjectsForGenezioManager(geneziomanager *initv1alpha1.GenezioManager) ([]*unstructured.Unstructured, error) {
+	spec := geneziomanager.Spec
+	flux := spec.CD.Flux
+
+	repoURL, branch := "", "main"
+	if repo := geneziomanager.Status.DeploymentRepository; repo != nil {
+		repoURL = repo.CloneURL
+		if repo.DefaultBranch != "" {
+			branch = repo.DefaultBranch
+		}
+	}
+	if spec.GitConfig.SSH != nil {
+		repoURL = spec.GitConfig.SSH.CloneURL
+	}
+	if repoURL == "" {
+		return nil, fmt.Errorf("the clone URL of the deployment repository is not known yet")
+	}
+
+	sourceRef := map[string]interface{}{
+		"kind": fluxGitRepositoryGVK.Kind,
+		"name": geneziomanager.Name,
+	}
+	gitRepository := map[string]interface{}{
+		"url":       repoURL,
+		"interval":  fluxInterval(flux),
+		"ref":       map[string]interface{}{"branch": branch},
+		"secretRef": map[string]interface{}{"name": fluxGitSecretName(geneziomanager.Name)},
+	}
+
+	var gvk schema.GroupVersionKind
+	var applier map[string]interface{}
+	switch fluxKind(flux) {
+	case fluxKindHelmRelease:
+		gvk = fluxHelmReleaseGVK
+		applier = map[string]interface{}{
+			"interval": fluxInterval(flux),
+			"chart": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"chart":     fluxPath(flux),
+					"sourceRef": sourceRef,
+				},
+			},
+		}
+	default:
+		gvk = fluxKustomizationGVK
+		applier = map[string]interface{}{
+			"interval":  fluxInterval(flux),
+			"path":      fluxPath(flux),
+			"prune":     flux.Prune,
+			"sourceRef": sourceRef,
+		}
+	}
+	if flux.TargetNamespace != "" {
+		applier["targetNamespace"] = flux.TargetNamespace
+	}
+
+	objects := []*unstructured.Unstructured{}
+	for _, object := range []struct {
+		gvk  schema.GroupVersionKind
+		spec map[string]interface{}
+	}{{fluxGitRepositoryGVK, gitRepository}, {gvk, applier}} {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{"spec": object.spec}}
+		// The type information is required by server-side apply
+		u.SetGroupVersionKind(object.gvk)
+		u.SetName(geneziomanager.Name)
+		u.SetNamespace(geneziomanager.Namespace)
+		u.SetLabels(labelsForGenezioManager(geneziomanager))
+		objects = append(objects, u)
+	}
+	return objects, nil
+}
+
+// reconcileFlux applies the Secret and the Flux objects deploying the applications
+// from the deployment repository when the flux engine is selected, and removes
+// those which are no longer needed otherwise.
+func (r *GenezioManagerReconciler) reconcileFlux(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) error {
+	log := log.FromContext(ctx)
+	if cdEngine(geneziomanager.Spec) != cdEngineFlux {
+		return r.deleteFluxObjects(ctx, geneziomanager)
+	}
+
+	data, err := r.fluxGitSecretData(ctx, geneziomanager)
+	if err != nil {
+		return err
+	}
+	secret := &corev1.Secret{
+		// The type information is required by server-side apply
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fluxGitSecretName(geneziomanager.Name),
+			Namespace: geneziomanager.Namespace,
+			Labels:    labelsForGenezioManager(geneziomanager),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+
+	objects, err := fluxObjectsForGenezioManager(geneziomanager)
+	if err != nil {
+		return err
+	}
+	for _, obj := range append([]client.Object{secret}, toClientObjects(objects)...) {
+		// Set the ownerRef so the objects are garbage collected with the custom resource
+		if err := ctrl.SetControllerReference(geneziomanager, obj, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Patch(ctx, obj, client.Apply, client.ForceOwnership, client.FieldOwner(fieldManager)); err != nil {
+			log.Error(err, "Failed to apply Flux object", "Kind", obj.GetObjectKind().GroupVersionKind().Kind,
+				"Namespace", obj.GetNamespace(), "Name", obj.GetName())
+			return err
+		}
+	}
+
+	// Switching between Kustomization and HelmRelease leaves the other one behind
+	stale := fluxHelmReleaseGVK
+	if fluxKind(geneziomanager.Spec.CD.Flux) == fluxKindHelmRelease {
+		stale = fluxKustomizationGVK
+	}
+	return r.deleteOwnedFluxObject(ctx, geneziomanager, stale)
+}
+
+func toClientObjects(objects []*unstructured.Unstructured) []client.Object {
+	result := make([]client.Object, 0, len(objects))
+	for _, obj := range objects {
+		result = append(result, obj)
+	}
+	return result
+}
+
+// deleteOwnedFluxObject deletes the Flux object of the given kind created for the
+// GenezioManager, if any. Nothing is deleted when Flux is not installed.
+func (r *GenezioManagerReconciler) deleteOwnedFluxObject(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager, gvk schema.GroupVersionKind) error {
+	found := &unstructured.Unstructured{}
+	found.SetGroupVersionKind(gvk)
+	err := r.Get(ctx, types.NamespacedName{Name: geneziomanager.Name, Namespace: geneziomanager.Namespace}, found)
+	// Discovery reports a missing API group as not found rather than as a missing kind
+	if meta.IsNoMatchError(err) || apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(found, geneziomanager) {
+		return nil
+	}
+	log.FromContext(ctx).Info("Deleting Flux object no longer needed", "Kind", gvk.Kind,
+		"Namespace", found.GetNamespace(), "Name", found.GetName())
+	return client.IgnoreNotFound(r.Delete(ctx, found))
+}
+
+// deleteFluxObjects removes the Flux objects once another engine is selected. The
+// Secret is applied along with them, so the Flux API is only queried while it exists.
+func (r *GenezioManagerReconciler) deleteFluxObjects(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) error {
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: fluxGitSecretName(geneziomanager.Name),
+		Namespace: geneziomanager.Namespace}, secret)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(secret, geneziomanager) {
+		return nil
+	}
+
+	for _, gvk := range []schema.GroupVersionKind{fluxGitRepositoryGVK, fluxKustomizationGVK, fluxHelmReleaseGVK} {
+		if err := r.deleteOwnedFluxObject(ctx, geneziomanager, gvk); err != nil {
+			return err
+		}
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, secret))
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestValidateCDConfig(t *testing.T) {
+	for name, spec := range map[string]initv1alpha1.GenezioManagerSpec{
+		"flux block with argocd": {CD: initv1alpha1.CDConfig{Flux: &initv1alpha1.FluxConfig{}}},
+		"flux without its block": {CD: initv1alpha1.CDConfig{Engine: cdEngineFlux}},
+		"both blocks with flux": {
+			CD:           initv1alpha1.CDConfig{Engine: cdEngineFlux, Flux: &initv1alpha1.FluxConfig{}},
+			ArgoCDConfig: initv1alpha1.ArgoCDConfig{URL: "https://argocd.example.com"},
+		},
+	} {
+		var specErr *specError
+		if err := validateCDConfig(spec); !errors.As(err, &specErr) || specErr.Reason != reasonInvalidCDConfig {
+			t.Errorf("%s: expected an %s error, got %v", name, reasonInvalidCDConfig, err)
+		}
+	}
+
+	spec := initv1alpha1.GenezioManagerSpec{
+		CD: initv1alpha1.CDConfig{Engine: cdEngineFlux, Flux: &initv1alpha1.FluxConfig{}},
+	}
+	if err := validateCDConfig(spec); err != nil {
+		t.Errorf("unexpected error for the flux engine: %v", err)
+	}
+}
+
+func TestFluxObjectsForGenezioManager(t *testing.T) {
+	geneziomanager := &initv1alpha1.GenezioManager{
+		ObjectMeta: metav1.ObjectMeta{Name: "manager", Namespace: "tenant-a"},
+		Spec: initv1alpha1.GenezioManagerSpec{
+			CD: initv1alpha1.CDConfig{Engine: cdEngineFlux, Flux: &initv1alpha1.FluxConfig{
+				Kind:     fluxKindHelmRelease,
+				Path:     "./charts/apps",
+				Interval: &metav1.Duration{Duration: 5 * time.Minute},
+			}},
+		},
+		Status: initv1alpha1.GenezioManagerStatus{
+			DeploymentRepository: &initv1alpha1.DeploymentRepositoryStatus{
+				CloneURL:      "https://gitea.example.com/genezio/deployments.git",
+				DefaultBranch: "trunk",
+			},
+		},
+	}
+
+	objects, err := fluxObjectsForGenezioManager(geneziomanager)
+	if err != nil {
+		t.Fatalf("unexpected error rendering the Flux objects: %v", err)
+	}
+	if len(objects) != 2 || objects[0].GetKind() != "GitRepository" || objects[1].GetKind() != fluxKindHelmRelease {
+		t.Fatalf("expected a GitRepository and a HelmRelease, got %v", objects)
+	}
+
+	branch, _, _ := unstructured.NestedString(objects[0].Object, "spec", "ref", "branch")
+	secretName, _, _ := unstructured.NestedString(objects[0].Object, "spec", "secretRef", "name")
+	interval, _, _ := unstructured.NestedString(objects[0].Object, "spec", "interval")
+	if branch != "trunk" || secretName != "manager-flux-git" || interval != "5m0s" {
+		t.Fatalf("unexpected GitRepository %v", objects[0].Object)
+	}
+	chart, _, _ := unstructured.NestedString(objects[1].Object, "spec", "chart", "spec", "chart")
+	source, _, _ := unstructured.NestedString(objects[1].Object, "spec", "chart", "spec", "sourceRef", "name")
+	if chart != "./charts/apps" || source != "manager" {
+		t.Fatalf("unexpected HelmRelease %v", objects[1].Object)
+	}
+}
@@ -18,14 +18,15 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"strings"
 	"time"
 
 	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -35,6 +36,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -49,25 +51,47 @@ type GenezioManagerReconciler struct {
 const (
 	// typeAvailableGenezioManager represents the status of the Deployment reconciliation
 	typeAvailableGenezioManager = "Available"
-	// typeDegradedGenezioManager represents the status used when the custom resource is deleted and the finalizer operations are must to occur.
+	// typeProgressingGenezioManager represents the status of the rollout of the Deployment
+	typeProgressingGenezioManager = "Progressing"
+	// typeDegradedGenezioManager represents the status used when the custom resource is deleted and the finalizer operations are must to occur,
+	// or when the spec references credentials which cannot be resolved.
 	typeDegradedGenezioManager = "Degraded"
+	// typeGitReadyGenezioManager represents whether the git provider accepts the credentials
+	// and holds the deployment repository
+	typeGitReadyGenezioManager = "GitReady"
+	// typeArgoCDReadyGenezioManager represents whether ArgoCD accepts the token of the
+	// genezio-manager and holds its AppProject
+	typeArgoCDReadyGenezioManager = "ArgoCDReady"
+	// typeRegistryReadyGenezioManager represents whether the operator could log into the container registry
+	typeRegistryReadyGenezioManager = "RegistryReady"
 )
 
 const geneziomanagerFinalizer = "finalizer.init.genezio.com"
 
+// fieldManager is the name used by the operator when applying the objects it owns
+const fieldManager = "genezio-operator"
+
 //+kubebuilder:rbac:groups=init.genezio.com,resources=geneziomanagers,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=init.genezio.com,resources=geneziomanagers/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=init.genezio.com,resources=geneziomanagers/finalizers,verbs=update
 //+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
-
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the GenezioManager object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=argoproj.io,resources=appprojects,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=gitrepositories,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=kustomizations,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=helm.toolkit.fluxcd.io,resources=helmreleases,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile converges the cluster on the spec of a GenezioManager. It checks the spec,
+// mints the tokens handed to the genezio-manager, applies its Deployment and the
+// objects around it (Service, Ingress, image pull Secret, ArgoCD and Flux objects) and
+// checks the systems it depends on, then derives the status from the Deployment and
+// the readiness of the dependencies. Deleting the custom resource runs the finalizer
+// for the objects which cannot be garbage collected through an owner reference.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.16.3/pkg/reconcile
@@ -142,11 +166,10 @@ func (r *GenezioManagerReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 
 			// Perform all operations required before remove the finalizer and allow
 			// the Kubernetes API to remove the custom resource.
-			// r.doFinalizerOperationsForgeneziomanager(geneziomanager)
-
-			// TODO(user): If you add operations to the doFinalizerOperationsForgeneziomanager method
-			// then you need to ensure that all worked fine before deleting and updating the Downgrade status
-			// otherwise, you should requeue here.
+			if err := r.doFinalizerOperationsForgeneziomanager(ctx, geneziomanager); err != nil {
+				log.Error(err, "Failed to perform finalizer operations for geneziomanager")
+				return ctrl.Result{}, err
+			}
 
 			// Re-fetch the geneziomanager Custom Resource before update the status
 			// so that we have the latest state of the resource on the cluster and we will avoid
@@ -180,79 +203,317 @@ func (r *GenezioManagerReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		return ctrl.Result{}, nil
 	}
 
-	// Check if the deployment already exists, if not create a new one
-	found := &appsv1.Deployment{}
-	err = r.Get(ctx, types.NamespacedName{Name: geneziomanager.Name, Namespace: geneziomanager.Namespace}, found)
-	if err != nil && apierrors.IsNotFound(err) {
-		// Define a new deployment
-		dep, err := r.deploymentForGenezioManager(geneziomanager)
-		if err != nil {
-			log.Error(err, "Failed to define new Deployment resource for GenezioManager")
-
-			// The following implementation will update the status
-			meta.SetStatusCondition(&geneziomanager.Status.Conditions, metav1.Condition{Type: typeAvailableGenezioManager,
-				Status: metav1.ConditionFalse, Reason: "Reconciling",
-				Message: fmt.Sprintf("Failed to create Deployment for the custom resource (%s): (%s)", geneziomanager.Name, err)})
+	// Make sure the spec is valid and its credentials can be resolved before rendering the Deployment
+	if err := r.checkSpec(ctx, geneziomanager); err != nil {
+		return r.reportSpecError(ctx, geneziomanager, err, "Failed to check the spec of geneziomanager")
+	}
 
-			if err := r.Status().Update(ctx, geneziomanager); err != nil {
-				log.Error(err, "Failed to update GenezioManager status")
-				return ctrl.Result{}, err
-			}
+	// The image pull Secret has to exist before the pods of the Deployment are scheduled
+	if err := r.reconcileRegistryPullSecret(ctx, geneziomanager); err != nil {
+		log.Error(err, "Failed to reconcile the image pull Secret for GenezioManager")
+		return ctrl.Result{}, err
+	}
+
+	// Define the desired deployment. It is computed on every pass so that changes
+	// to the spec are rolled out and manual edits of the fields we own are reverted.
+	dep, err := r.deploymentForGenezioManager(geneziomanager)
+	if err != nil {
+		log.Error(err, "Failed to define new Deployment resource for GenezioManager")
 
+		// The following implementation will update the status
+		meta.SetStatusCondition(&geneziomanager.Status.Conditions, metav1.Condition{Type: typeAvailableGenezioManager,
+			Status: metav1.ConditionFalse, Reason: "Reconciling",
+			Message: fmt.Sprintf("Failed to create Deployment for the custom resource (%s): (%s)", geneziomanager.Name, err)})
+
+		if err := r.Status().Update(ctx, geneziomanager); err != nil {
+			log.Error(err, "Failed to update GenezioManager status")
 			return ctrl.Result{}, err
 		}
 
-		log.Info("Creating a new Deployment",
+		return ctrl.Result{}, err
+	}
+
+	// Roll the pods when one of the Secrets they read changes
+	if err := r.stampSecretHash(ctx, &dep.Spec.Template, dep.Namespace); err != nil {
+		log.Error(err, "Failed to hash the Secrets of the Deployment")
+		return ctrl.Result{}, err
+	}
+
+	// The selector of a Deployment is immutable. Deployments created by older versions
+	// of the operator selected on every label, including the version, so they have to
+	// be recreated before they can be converged.
+	found := &appsv1.Deployment{}
+	err = r.Get(ctx, types.NamespacedName{Name: dep.Name, Namespace: dep.Namespace}, found)
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Error(err, "Failed to get Deployment")
+		// Let's return the error for the reconciliation be re-trigged again
+		return ctrl.Result{}, err
+	}
+	if err == nil && !equality.Semantic.DeepEqual(found.Spec.Selector, dep.Spec.Selector) {
+		log.Info("Deleting Deployment with an outdated selector",
+			"Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+		if err := r.Delete(ctx, found, client.PropagationPolicy(metav1.DeletePropagationForeground)); err != nil {
+			log.Error(err, "Failed to delete Deployment",
+				"Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+
+		// The Deployment will be recreated once the deletion went through
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+	}
+
+	// Server-side apply creates the Deployment or converges the live object. Only the
+	// fields set by the operator are owned by its field manager, so fields managed by
+	// others (e.g. annotations added by kubectl rollout restart) are left alone.
+	if err := r.Patch(ctx, dep, client.Apply, client.ForceOwnership, client.FieldOwner(fieldManager)); err != nil {
+		log.Error(err, "Failed to apply Deployment",
 			"Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
-		if err = r.Create(ctx, dep); err != nil {
-			log.Error(err, "Failed to create new Deployment",
-				"Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
+		return ctrl.Result{}, err
+	}
+
+	// Expose the genezio-manager through its Service and, if requested, an Ingress
+	if err := r.reconcileNetworking(ctx, geneziomanager); err != nil {
+		log.Error(err, "Failed to reconcile networking for GenezioManager")
+		return ctrl.Result{}, err
+	}
+
+	// The Gitea access token and the image digest are only refreshed once the Deployment
+	// has been applied, so that an unreachable Gitea or registry does not hold it back.
+	// Their spec errors are reported on the Degraded condition, transient failures keep
+	// the current token and digest and are retried with a backoff.
+	var transientErr error
+	var specErr *specError
+	if err := r.reconcileGiteaToken(ctx, geneziomanager); err != nil {
+		if errors.As(err, &specErr) {
+			return r.reportSpecError(ctx, geneziomanager, err, "Failed to reconcile the Gitea access token for geneziomanager")
+		}
+		log.Error(err, "Failed to reconcile the Gitea access token for GenezioManager")
+		transientErr = err
+	}
+	if err := r.resolveImageDigest(ctx, geneziomanager); err != nil {
+		if errors.As(err, &specErr) {
+			return r.reportSpecError(ctx, geneziomanager, err, "Failed to resolve the image digest for geneziomanager")
+		}
+		log.Error(err, "Failed to resolve the image digest for GenezioManager")
+		transientErr = err
+	}
+
+	// Roll out a newly resolved digest or minted token right away
+	if updated, err := r.deploymentForGenezioManager(geneziomanager); err != nil {
+		log.Error(err, "Failed to define new Deployment resource for GenezioManager")
+		return ctrl.Result{}, err
+	} else if err := r.stampSecretHash(ctx, &updated.Spec.Template, updated.Namespace); err != nil {
+		log.Error(err, "Failed to hash the Secrets of the Deployment")
+		return ctrl.Result{}, err
+	} else if podTemplateChanged(dep, updated) {
+		if err := r.Patch(ctx, updated, client.Apply, client.ForceOwnership, client.FieldOwner(fieldManager)); err != nil {
+			log.Error(err, "Failed to apply Deployment",
+				"Deployment.Namespace", updated.Namespace, "Deployment.Name", updated.Name)
 			return ctrl.Result{}, err
 		}
+		dep = updated
+	}
 
-		// Deployment created successfully
-		// We will requeue the reconciliation so that we can ensure the state
-		// and move forward for the next operations
-		return ctrl.Result{RequeueAfter: time.Minute}, nil
-	} else if err != nil {
-		log.Error(err, "Failed to get Deployment")
-		// Let's return the error for the reconciliation be re-trigged again
+	// The external systems are only called when they are due to be checked again. Their
+	// failures are reported on the GitReady, ArgoCDReady and RegistryReady conditions
+	// and do not hold back the Deployment applied above.
+	now := time.Now()
+	checkDependencies := dependencyCheckDue(geneziomanager, dep.Spec.Template.Annotations[secretHashAnnotation], now)
+	if checkDependencies {
+		if err := r.ensureDeploymentRepository(ctx, geneziomanager); err != nil {
+			log.Info("Failed to ensure the deployment repository", "error", err.Error())
+		}
+	}
+
+	// Declare the deployment repository to ArgoCD and Flux once it is known, GitReady
+	// reports why it is not
+	if deploymentRepositoryKnown(geneziomanager) {
+		if err := r.reconcileArgoCDRepository(ctx, geneziomanager); err != nil {
+			log.Error(err, "Failed to reconcile the ArgoCD repository for GenezioManager")
+			return ctrl.Result{}, err
+		}
+		if err := r.reconcileArgoCDProject(ctx, geneziomanager); err != nil {
+			log.Error(err, "Failed to reconcile the ArgoCD project for GenezioManager")
+			return ctrl.Result{}, err
+		}
+		if err := r.reconcileFlux(ctx, geneziomanager); err != nil {
+			log.Error(err, "Failed to reconcile Flux for GenezioManager")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Project tokens are generated for a role of the AppProject applied above
+	if err := r.reconcileArgoCDToken(ctx, geneziomanager); err != nil {
+		return r.reportSpecError(ctx, geneziomanager, err, "Failed to reconcile the ArgoCD token for geneziomanager")
+	}
+
+	// Report whether ArgoCD and the container registry accept the credentials
+	if checkDependencies {
+		if err := r.checkArgoCD(ctx, geneziomanager); err != nil {
+			log.Error(err, "Failed to check ArgoCD for GenezioManager")
+			return ctrl.Result{}, err
+		}
+		if err := r.checkRegistry(ctx, geneziomanager); err != nil {
+			log.Error(err, "Failed to check the container registry for GenezioManager")
+			return ctrl.Result{}, err
+		}
+		geneziomanager.Status.DependenciesCheckTime = &metav1.Time{Time: now.UTC().Truncate(time.Second)}
+	}
+
+	// The following implementation will update the status from the applied Deployment,
+	// which carries the live status returned by the API server
+	if err := r.setStatusFromDeployment(ctx, geneziomanager, dep); err != nil {
+		log.Error(err, "Failed to compute GenezioManager status")
 		return ctrl.Result{}, err
 	}
 
-	// The following implementation will update the status
-	meta.SetStatusCondition(&geneziomanager.Status.Conditions, metav1.Condition{Type: typeAvailableGenezioManager,
-		Status: metav1.ConditionTrue, Reason: "Reconciling",
-		Message: fmt.Sprintf("Deployment for custom resource (%s) created successfully", geneziomanager.Name)})
+	if err := r.Status().Update(ctx, geneziomanager); err != nil {
+		log.Error(err, "Failed to update GenezioManager status")
+		return ctrl.Result{}, err
+	}
+
+	// A transient failure of Gitea or the registry is retried with a backoff
+	if transientErr != nil {
+		return ctrl.Result{}, transientErr
+	}
+
+	// Come back when one of the tokens managed by the operator has to be replaced
+	// or the external systems have to be checked again
+	return ctrl.Result{RequeueAfter: shortestDelay(giteaTokenRotationDelay(geneziomanager, now),
+		argoCDTokenRefreshDelay(geneziomanager, now), dependencyCheckDelay(geneziomanager, now))}, nil
+}
+
+// reportSpecError sets the Degraded condition when err is a specError and requeues
+// after a minute, since the external systems behind the spec are not watched. Other
+// errors are returned so that the reconciliation is retried with a backoff.
+func (r *GenezioManagerReconciler) reportSpecError(ctx context.Context, geneziomanager *initv1alpha1.GenezioManager,
+	err error, msg string) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	var specErr *specError
+	if !errors.As(err, &specErr) {
+		log.Error(err, msg)
+		// Keep the readiness conditions set by the probes before retrying
+		if err := r.Status().Update(ctx, geneziomanager); err != nil {
+			log.Error(err, "Failed to update GenezioManager status")
+		}
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Invalid spec for geneziomanager", "reason", specErr.Reason, "message", specErr.Message)
+	setSpecErrorConditions(geneziomanager, specErr)
 
 	if err := r.Status().Update(ctx, geneziomanager); err != nil {
 		log.Error(err, "Failed to update GenezioManager status")
 		return ctrl.Result{}, err
 	}
+	return ctrl.Result{RequeueAfter: time.Minute}, nil
+}
+
+// reasonTokenRevocationFailed is used on the Warning event emitted when a token minted
+// for the genezio-manager cannot be revoked while the GenezioManager is deleted
+const reasonTokenRevocationFailed = "TokenRevocationFailed"
+
+// recordWarning emits a Warning event on the GenezioManager. Reconcilers created
+// without a recorder, as in the tests, only log.
+func (r *GenezioManagerReconciler) recordWarning(geneziomanager *initv1alpha1.GenezioManager, reason, message string) {
+	if r.Recorder != nil {
+		r.Recorder.Event(geneziomanager, corev1.EventTypeWarning, reason, message)
+	}
+}
+
+// setSpecErrorConditions reports a spec which cannot be reconciled on the Degraded
+// and Available conditions
+func setSpecErrorConditions(geneziomanager *initv1alpha1.GenezioManager, specErr *specError) {
+	meta.SetStatusCondition(&geneziomanager.Status.Conditions, metav1.Condition{Type: typeDegradedGenezioManager,
+		Status: metav1.ConditionTrue, Reason: specErr.Reason, Message: specErr.Message})
+	meta.SetStatusCondition(&geneziomanager.Status.Conditions, metav1.Condition{Type: typeAvailableGenezioManager,
+		Status: metav1.ConditionFalse, Reason: specErr.Reason,
+		Message: fmt.Sprintf("Unable to reconcile the spec of the custom resource (%s)", geneziomanager.Name)})
+}
+
+// shortestDelay returns the shortest of the non-zero delays, or zero if there is none
+func shortestDelay(delays ...time.Duration) time.Duration {
+	var shortest time.Duration
+	for _, delay := range delays {
+		if delay > 0 && (shortest == 0 || delay < shortest) {
+			shortest = delay
+		}
+	}
+	return shortest
+}
+
+// doFinalizerOperationsForgeneziomanager revokes the tokens minted for the
+// genezio-manager and removes the objects of the GenezioManager which cannot be
+// garbage collected through an owner reference
+func (r *GenezioManagerReconciler) doFinalizerOperationsForgeneziomanager(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) error {
+	if err := r.revokeGiteaToken(ctx, geneziomanager); err != nil {
+		return err
+	}
+	if err := r.revokeArgoCDToken(ctx, geneziomanager); err != nil {
+		return err
+	}
+	if err := r.deleteArgoCDRepositorySecrets(ctx, geneziomanager, ""); err != nil {
+		return err
+	}
+	if err := r.deleteRegistryPullSecretReplicas(ctx, geneziomanager, nil); err != nil {
+		return err
+	}
+	return r.deleteArgoCDProjects(ctx, geneziomanager, "")
+}
+
+// checkSpec validates the spec of the GenezioManager and the objects it references
+func (r *GenezioManagerReconciler) checkSpec(ctx context.Context, geneziomanager *initv1alpha1.GenezioManager) error {
+	if err := validateGitConfig(geneziomanager.Spec.GitConfig); err != nil {
+		return err
+	}
+	if err := validateGitSSHConfig(geneziomanager.Spec.GitConfig.SSH); err != nil {
+		return err
+	}
+	if err := validateCDConfig(geneziomanager.Spec); err != nil {
+		return err
+	}
+	if err := validateArgoCDConfig(geneziomanager.Spec.ArgoCDConfig); err != nil {
+		return err
+	}
+	if err := validateRegistryConfig(geneziomanager.Spec.ContainerRegistryConfig); err != nil {
+		return err
+	}
+	if err := validateDomain(geneziomanager.Spec); err != nil {
+		return err
+	}
+	if err := r.checkCredentials(ctx, geneziomanager); err != nil {
+		return err
+	}
+	return r.checkSSHHostKeys(ctx, geneziomanager)
+}
 
-	return ctrl.Result{}, nil
+// podTemplateChanged reports whether the image or the Secrets of the operand differ
+// between the applied Deployment and the desired one
+func podTemplateChanged(applied, desired *appsv1.Deployment) bool {
+	return applied.Spec.Template.Spec.Containers[0].Image != desired.Spec.Template.Spec.Containers[0].Image ||
+		applied.Spec.Template.Annotations[secretHashAnnotation] != desired.Spec.Template.Annotations[secretHashAnnotation]
 }
 
-// imageForGenezioManager gets the Operand image which is managed by this controller
-// from the GENEZIO_MANAGER_IMAGE environment variable defined in the config/manager/manager.yaml
-func imageForGenezioManager() (string, error) {
-	var imageEnvVar = "GENEZIO_MANAGER_IMAGE"
-	image, found := os.LookupEnv(imageEnvVar)
-	if !found {
-		return "", fmt.Errorf("unable to find %s environment variable with the image", imageEnvVar)
+// selectorLabelsForGenezioManager returns the labels used to select the pods of the
+// operand. They must not change over the lifetime of the Deployment.
+func selectorLabelsForGenezioManager(name string) map[string]string {
+	return map[string]string{"app.kubernetes.io/name": "GenezioManager",
+		"app.kubernetes.io/instance": name,
 	}
-	return image, nil
 }
 
-func labelsForGenezioManager(name string) map[string]string {
-	var imageTag string
-	image, err := imageForGenezioManager()
-	if err == nil {
-		imageTag = strings.Split(image, ":")[1]
+// labelsForGenezioManager returns the labels of the objects managed for the
+// GenezioManager, with the version of the operand image
+func labelsForGenezioManager(geneziomanager *initv1alpha1.GenezioManager) map[string]string {
+	var version string
+	if image, err := imageForGenezioManager(geneziomanager); err == nil {
+		version = image.versionLabel()
 	}
 	return map[string]string{"app.kubernetes.io/name": "GenezioManager",
-		"app.kubernetes.io/instance":   name,
-		"app.kubernetes.io/version":    imageTag,
+		"app.kubernetes.io/instance":   geneziomanager.Name,
+		"app.kubernetes.io/version":    version,
 		"app.kubernetes.io/part-of":    "genezio-operator",
 		"app.kubernetes.io/created-by": "controller-manager",
 	}
@@ -261,31 +522,54 @@ func labelsForGenezioManager(name string) map[string]string {
 // deploymentForGenezioManager returns a GenezioManager Deployment object
 func (r *GenezioManagerReconciler) deploymentForGenezioManager(
 	geneziomanager *initv1alpha1.GenezioManager) (*appsv1.Deployment, error) {
-	ls := labelsForGenezioManager(geneziomanager.Name)
+	ls := labelsForGenezioManager(geneziomanager)
 	// Get the Operand image
-	image, err := imageForGenezioManager()
+	image, err := imageForGenezioManager(geneziomanager)
 	if err != nil {
 		return nil, err
 	}
 
-	// Extract the git provider configuration
-	var gitUser, gitURL, gitPassword, gitToken string
-	switch geneziomanager.Spec.GitConfig.Provider {
-	case "gitea":
-		gitUser = geneziomanager.Spec.GitConfig.Gitea.Username
-		gitURL = geneziomanager.Spec.GitConfig.Gitea.URL
-		gitPassword = geneziomanager.Spec.GitConfig.Gitea.Password
-		gitToken = geneziomanager.Spec.GitConfig.Gitea.Token
+	// Credentials are either given inline or resolved from a Secret by the kubelet
+	if err := validateCredentials(geneziomanager.Spec); err != nil {
+		return nil, err
+	}
+	creds := credentialsFor(geneziomanager)
+	// The operand gets the access token minted by the operator instead of the Gitea password
+	if usesMintedGiteaToken(geneziomanager.Spec.GitConfig) {
+		creds.git.token = mintedGiteaTokenCredential(geneziomanager.Name)
+		creds.git.password = credential{}
+	}
+	// The operand gets the token generated by the operator instead of the ArgoCD password
+	if usesArgoCDLogin(geneziomanager.Spec.ArgoCDConfig) {
+		creds.argoCDPassword = argoCDTokenCredential(geneziomanager.Name)
+	}
+
+	// Render the git provider configuration
+	provider, err := gitProviderFor(geneziomanager.Spec.GitConfig)
+	if err != nil {
+		return nil, err
+	}
+	gitOperand := provider.Render(geneziomanager.Spec.GitConfig, creds.git).
+		merge(renderGitSSHOperandConfig(geneziomanager.Spec.GitConfig.SSH, creds.sshPrivateKey, creds.sshKnownHosts))
+	repoName := geneziomanager.Spec.GitConfig.DeployementRepoName
+	if gitOperand.repoName != "" {
+		repoName = gitOperand.repoName
 	}
 
 	dep := &appsv1.Deployment{
+		// The type information is required by server-side apply
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: appsv1.SchemeGroupVersion.String(),
+			Kind:       "Deployment",
+		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      geneziomanager.Name,
 			Namespace: geneziomanager.Namespace,
+			Labels:    ls,
 		},
 		Spec: appsv1.DeploymentSpec{
 			Selector: &metav1.LabelSelector{
-				MatchLabels: ls,
+				MatchLabels: selectorLabelsForGenezioManager(geneziomanager.Name),
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
@@ -301,8 +585,9 @@ func (r *GenezioManagerReconciler) deploymentForGenezioManager(
 							Type: corev1.SeccompProfileTypeRuntimeDefault,
 						},
 					},
+					Volumes: gitOperand.volumes,
 					Containers: []corev1.Container{{
-						Image: image,
+						Image: image.String(),
 						Name:  "genezio-manager",
 						Env: []corev1.EnvVar{
 							{
@@ -311,7 +596,7 @@ func (r *GenezioManagerReconciler) deploymentForGenezioManager(
 							},
 							{
 								Name:  "DOMAIN",
-								Value: "local",
+								Value: domainForGenezioManager(geneziomanager.Spec),
 							},
 							{
 								Name:  "CHART_REPO",
@@ -323,16 +608,13 @@ func (r *GenezioManagerReconciler) deploymentForGenezioManager(
 							},
 							{
 								Name:  "DEPLOYMENT_REPO_NAME",
-								Value: geneziomanager.Spec.GitConfig.DeployementRepoName,
+								Value: repoName,
 							},
 							{
 								Name:  "ARGOCD_URL",
 								Value: geneziomanager.Spec.ArgoCDConfig.URL,
 							},
-							{
-								Name:  "ARGOCD_TOKEN",
-								Value: geneziomanager.Spec.ArgoCDConfig.Password,
-							},
+							creds.argoCDPassword.envVar("ARGOCD_TOKEN"),
 							// Container registry data
 							{
 								Name:  "REGISTRY_URL",
@@ -342,30 +624,16 @@ func (r *GenezioManagerReconciler) deploymentForGenezioManager(
 								Name:  "REGISTRY_USER",
 								Value: geneziomanager.Spec.ContainerRegistryConfig.Username,
 							},
-							{
-								Name:  "REGISTRY_PASSWORD",
-								Value: geneziomanager.Spec.ContainerRegistryConfig.Password,
-							},
+							creds.registryPassword.envVar("REGISTRY_PASSWORD"),
 							// Git data
 							{
-								Name:  "GIT_USER",
-								Value: gitUser,
-							},
-							{
-								Name:  "GIT_URL",
-								Value: gitURL,
-							},
-							{
-								Name:  "GIT_PASSWORD",
-								Value: gitPassword,
-							},
-							{
-								Name:  "GIT_TOKEN",
-								Value: gitToken,
+								Name:  "GIT_PROVIDER",
+								Value: geneziomanager.Spec.GitConfig.Provider,
 							},
 						},
+						VolumeMounts: gitOperand.volumeMounts,
 
-						ImagePullPolicy: corev1.PullAlways,
+						ImagePullPolicy: imagePullPolicyForGenezioManager(geneziomanager.Spec),
 						// Ensure restrictive context for the container
 						// More info: https://kubernetes.io/docs/concepts/security/pod-security-standards/#restricted
 						SecurityContext: &corev1.SecurityContext{
@@ -390,7 +658,7 @@ func (r *GenezioManagerReconciler) deploymentForGenezioManager(
 						},
 						Ports: []corev1.ContainerPort{{
 							ContainerPort: geneziomanager.Spec.ContainerPort,
-							Name:          "genezio-manager",
+							Name:          containerPortName,
 						}},
 					}},
 				},
@@ -398,6 +666,46 @@ func (r *GenezioManagerReconciler) deploymentForGenezioManager(
 		},
 	}
 
+	// The git provider renders the rest of the git data
+	container := &dep.Spec.Template.Spec.Containers[0]
+	container.Env = append(container.Env, gitOperand.env...)
+
+	// The genezio-manager image and the applications are pulled with the generated Secret
+	if usesRegistryPullSecret(geneziomanager.Spec.ContainerRegistryConfig) {
+		dep.Spec.Template.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{
+			Name: registryPullSecretName(geneziomanager.Name),
+		}}
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  "REGISTRY_PULL_SECRET_NAME",
+			Value: registryPullSecretName(geneziomanager.Name),
+		})
+	}
+
+	// Applications are created in the AppProject managed by the operator, if any
+	if geneziomanager.Spec.ArgoCDConfig.AppProject != nil {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  "ARGOCD_PROJECT",
+			Value: argoCDProjectName(geneziomanager),
+		})
+	}
+
+	// The certificate and ingress class the applications are published with
+	container.Env = append(container.Env, domainEnvForGenezioManager(geneziomanager.Spec)...)
+
+	// The CD engine tells the genezio-manager how the applications are deployed
+	container.Env = append(container.Env, corev1.EnvVar{
+		Name:  "CD_ENGINE",
+		Value: cdEngine(geneziomanager.Spec),
+	})
+	if cdEngine(geneziomanager.Spec) == cdEngineFlux {
+		container.Env = append(container.Env, fluxEnvForGenezioManager(geneziomanager)...)
+	}
+
+	// Secret volumes are owned by root, the pod group is given read access to the SSH key
+	if geneziomanager.Spec.GitConfig.SSH != nil {
+		dep.Spec.Template.Spec.SecurityContext.FSGroup = &[]int64{1001}[0]
+	}
+
 	// Set the ownerRef for the Deployment
 	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
 	if err := ctrl.SetControllerReference(geneziomanager, dep, r.Scheme); err != nil {
@@ -408,8 +716,24 @@ func (r *GenezioManagerReconciler) deploymentForGenezioManager(
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *GenezioManagerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Index the GenezioManagers by the Secrets they reference to map Secret events back to them
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &initv1alpha1.GenezioManager{},
+		referencedSecretsIndex, referencedSecretNames); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&initv1alpha1.GenezioManager{}).
 		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Owns(&networkingv1.Ingress{}).
+		Owns(&corev1.Secret{}).
+		// Pods are owned by the ReplicaSets of the Deployment, so they are mapped
+		// back to their GenezioManager through the instance label
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(requestsForPod)).
+		// Secrets referenced by the spec are not owned, they are mapped through the field index
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.requestsForSecret)).
+		// Namespaces selected later get a copy of the image pull Secret
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.requestsForNamespace)).
 		Complete(r)
 }
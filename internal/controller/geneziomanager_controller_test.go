@@ -18,11 +18,22 @@ package controller
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -30,6 +41,81 @@ import (
 	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
 )
 
+var (
+	giteaServer     *httptest.Server
+	giteaServerOnce sync.Once
+	// giteaRevokedTokens records the names of the access tokens deleted on giteaServer
+	giteaRevokedTokens   []string
+	giteaRevokedTokensMu sync.Mutex
+
+	registryServer     *httptest.Server
+	registryServerOnce sync.Once
+)
+
+// giteaServerURL returns the URL of a Gitea stand-in holding the genezio/deployments repository
+func giteaServerURL() string {
+	giteaServerOnce.Do(func() {
+		giteaServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username, password, _ := r.BasicAuth()
+			if r.Header.Get("Authorization") != "token gitea-token" &&
+				(username != "genezio" || password != "gitea-password") {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if name, found := strings.CutPrefix(r.URL.Path, "/api/v1/users/genezio/tokens/"); found &&
+				r.Method == http.MethodDelete {
+				giteaRevokedTokensMu.Lock()
+				defer giteaRevokedTokensMu.Unlock()
+				giteaRevokedTokens = append(giteaRevokedTokens, name)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			switch r.URL.Path {
+			case "/api/v1/users/genezio/tokens":
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`{"id":1,"sha1":"minted-token"}`))
+			case "/api/v1/user":
+				_, _ = w.Write([]byte(`{"login":"genezio"}`))
+			case "/api/v1/repos/genezio/deployments":
+				_, _ = w.Write([]byte(`{"clone_url":"https://gitea.example.com/genezio/deployments.git",` +
+					`"default_branch":"main"}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	})
+	return giteaServer.URL
+}
+
+// registryServerURL returns the URL of a container registry stand-in accepting the
+// genezio user with the password registry-password through basic auth
+func registryServerURL() string {
+	registryServerOnce.Do(func() {
+		registryServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if username, password, _ := r.BasicAuth(); username != "genezio" || password != "registry-password" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{}`))
+		}))
+	})
+	return registryServer.URL
+}
+
+// giteaGitConfig returns a minimal valid git configuration
+func giteaGitConfig() initv1alpha1.GitConfig {
+	return initv1alpha1.GitConfig{
+		Provider:            "gitea",
+		DeployementRepoName: "deployments",
+		Gitea: initv1alpha1.GiteaProvider{
+			URL:      giteaServerURL(),
+			Username: "genezio",
+			Token:    "gitea-token",
+		},
+	}
+}
+
 var _ = Describe("GenezioManager Controller", func() {
 	Context("When reconciling a resource", func() {
 		const resourceName = "test-resource"
@@ -43,6 +129,8 @@ var _ = Describe("GenezioManager Controller", func() {
 		geneziomanager := &initv1alpha1.GenezioManager{}
 
 		BeforeEach(func() {
+			Expect(os.Setenv("GENEZIO_MANAGER_IMAGE", "example.com/genezio-manager:test")).To(Succeed())
+
 			By("creating the custom resource for the Kind GenezioManager")
 			err := k8sClient.Get(ctx, typeNamespacedName, geneziomanager)
 			if err != nil && errors.IsNotFound(err) {
@@ -51,7 +139,7 @@ var _ = Describe("GenezioManager Controller", func() {
 						Name:      resourceName,
 						Namespace: "default",
 					},
-					// TODO(user): Specify other spec details if needed.
+					Spec: initv1alpha1.GenezioManagerSpec{GitConfig: giteaGitConfig(), ContainerPort: 8080},
 				}
 				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
 			}
@@ -81,4 +169,611 @@ var _ = Describe("GenezioManager Controller", func() {
 			// Example: If you expect a certain status condition after reconciliation, verify it here.
 		})
 	})
+
+	Context("When credentials are referenced from Secrets", func() {
+		const resourceName = "test-secret-refs"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			Expect(os.Setenv("GENEZIO_MANAGER_IMAGE", "example.com/genezio-manager:test")).To(Succeed())
+
+			By("creating the Secret holding the credentials")
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				StringData: map[string]string{"token": "gitea-token", "registry": "registry-password"},
+			}
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			resource := &initv1alpha1.GenezioManager{}
+			if err := k8sClient.Get(ctx, typeNamespacedName, resource); err == nil {
+				resource.Finalizers = nil
+				Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+				Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			}
+			dep := &appsv1.Deployment{}
+			if err := k8sClient.Get(ctx, typeNamespacedName, dep); err == nil {
+				Expect(k8sClient.Delete(ctx, dep)).To(Succeed())
+			}
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, secret)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+		})
+
+		reconcileResource := func() {
+			controllerReconciler := &GenezioManagerReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		It("should render SecretKeyRefs instead of plaintext values", func() {
+			resource := &initv1alpha1.GenezioManager{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: initv1alpha1.GenezioManagerSpec{
+					GitConfig: initv1alpha1.GitConfig{
+						Provider:            "gitea",
+						DeployementRepoName: "deployments",
+						Gitea: initv1alpha1.GiteaProvider{
+							URL:             giteaServerURL(),
+							Username:        "genezio",
+							TokenSecretName: resourceName,
+							TokenSecretKey:  "token",
+						},
+					},
+					ContainerRegistryConfig: initv1alpha1.ContainerRegistryConfig{
+						URL:                registryServerURL(),
+						Username:           "genezio",
+						PasswordSecretName: resourceName,
+						PasswordSecretKey:  "registry",
+					},
+					ContainerPort: 8080,
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+
+			reconcileResource()
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			env := map[string]corev1.EnvVar{}
+			for _, e := range dep.Spec.Template.Spec.Containers[0].Env {
+				env[e.Name] = e
+			}
+			for name, key := range map[string]string{"GIT_TOKEN": "token", "REGISTRY_PASSWORD": "registry"} {
+				Expect(env[name].Value).To(BeEmpty())
+				Expect(env[name].ValueFrom).NotTo(BeNil())
+				Expect(env[name].ValueFrom.SecretKeyRef.Name).To(Equal(resourceName))
+				Expect(env[name].ValueFrom.SecretKeyRef.Key).To(Equal(key))
+			}
+			Expect(dep.Spec.Template.Spec.ImagePullSecrets).To(Equal([]corev1.LocalObjectReference{{
+				Name: registryPullSecretName(resourceName),
+			}}))
+			secretHash := dep.Spec.Template.Annotations[secretHashAnnotation]
+			Expect(secretHash).NotTo(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.DeploymentRepository).To(Equal(&initv1alpha1.DeploymentRepositoryStatus{
+				CloneURL:      "https://gitea.example.com/genezio/deployments.git",
+				DefaultBranch: "main",
+			}))
+			Expect(resource.Status.SecretHash).To(Equal(secretHash))
+			for _, conditionType := range []string{typeGitReadyGenezioManager, typeRegistryReadyGenezioManager} {
+				condition := meta.FindStatusCondition(resource.Status.Conditions, conditionType)
+				Expect(condition).NotTo(BeNil())
+				Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			}
+			Expect(meta.FindStatusCondition(resource.Status.Conditions, typeArgoCDReadyGenezioManager)).To(BeNil())
+		})
+
+		It("should mint a Gitea access token instead of passing the password", func() {
+			gitConfig := giteaGitConfig()
+			gitConfig.Gitea.Token = ""
+			gitConfig.Gitea.Password = "gitea-password"
+			resource := &initv1alpha1.GenezioManager{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: initv1alpha1.GenezioManagerSpec{GitConfig: gitConfig, ContainerPort: 8080},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+
+			reconcileResource()
+
+			tokenSecret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: giteaTokenSecretName(resourceName),
+				Namespace: "default"}, tokenSecret)).To(Succeed())
+			Expect(string(tokenSecret.Data[giteaTokenSecretKey])).To(Equal("minted-token"))
+			Expect(metav1.IsControlledBy(tokenSecret, resource)).To(BeTrue())
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			env := map[string]corev1.EnvVar{}
+			for _, e := range dep.Spec.Template.Spec.Containers[0].Env {
+				env[e.Name] = e
+			}
+			Expect(env["GIT_PASSWORD"].Value).To(BeEmpty())
+			Expect(env["GIT_PASSWORD"].ValueFrom).To(BeNil())
+			Expect(env["GIT_TOKEN"].ValueFrom).NotTo(BeNil())
+			Expect(env["GIT_TOKEN"].ValueFrom.SecretKeyRef.Name).To(Equal(giteaTokenSecretName(resourceName)))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.GiteaTokenRotationTime).NotTo(BeNil())
+
+			By("deleting the custom resource")
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			reconcileResource()
+			giteaRevokedTokensMu.Lock()
+			defer giteaRevokedTokensMu.Unlock()
+			Expect(giteaRevokedTokens).To(ContainElement(tokenSecret.Annotations[giteaTokenNameAnnotation]))
+
+			Expect(k8sClient.Delete(ctx, tokenSecret)).To(Succeed())
+		})
+
+		// finalizeDespiteRevocationFailure deletes a resource whose minted token Secret
+		// cannot be revoked and expects the finalizer to be removed with a Warning event
+		finalizeDespiteRevocationFailure := func(resource *initv1alpha1.GenezioManager, tokenSecret *corev1.Secret) {
+			resource.Finalizers = []string{geneziomanagerFinalizer}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			Expect(k8sClient.Create(ctx, tokenSecret)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, tokenSecret))).To(Succeed())
+			})
+
+			By("deleting the custom resource while the issuer of the token is unreachable")
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			recorder := record.NewFakeRecorder(10)
+			controllerReconciler := &GenezioManagerReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: recorder,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Get(ctx, typeNamespacedName, &initv1alpha1.GenezioManager{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning " + reasonTokenRevocationFailed)))
+		}
+
+		It("should remove the finalizer when the Gitea access token cannot be revoked", func() {
+			gitConfig := giteaGitConfig()
+			gitConfig.Gitea.URL = "http://127.0.0.1:1"
+			gitConfig.Gitea.Token = ""
+			gitConfig.Gitea.Password = "gitea-password"
+			finalizeDespiteRevocationFailure(&initv1alpha1.GenezioManager{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: initv1alpha1.GenezioManagerSpec{GitConfig: gitConfig, ContainerPort: 8080},
+			}, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      giteaTokenSecretName(resourceName),
+					Namespace: "default",
+					Annotations: map[string]string{
+						giteaTokenNameAnnotation: "genezio-operator-1",
+						giteaTokenUserAnnotation: "genezio",
+					},
+				},
+			})
+		})
+
+		It("should remove the finalizer when the ArgoCD token cannot be revoked", func() {
+			finalizeDespiteRevocationFailure(&initv1alpha1.GenezioManager{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: initv1alpha1.GenezioManagerSpec{
+					GitConfig: giteaGitConfig(),
+					ArgoCDConfig: initv1alpha1.ArgoCDConfig{
+						URL:      "http://127.0.0.1:1",
+						Username: "admin",
+						Password: "argocd-password",
+					},
+					ContainerPort: 8080,
+				},
+			}, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      argoCDTokenSecretName(resourceName),
+					Namespace: "default",
+					Annotations: map[string]string{
+						argoCDTokenSourceAnnotation: "account/admin",
+						argoCDTokenIDAnnotation:     "genezio-operator-1",
+					},
+				},
+			})
+		})
+
+		It("should set the Degraded condition when a referenced key is missing", func() {
+			resource := &initv1alpha1.GenezioManager{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: initv1alpha1.GenezioManagerSpec{
+					GitConfig: giteaGitConfig(),
+					ArgoCDConfig: initv1alpha1.ArgoCDConfig{
+						URL:                "https://argocd.example.com",
+						PasswordSecretName: resourceName,
+						PasswordSecretKey:  "missing",
+					},
+					ContainerPort: 8080,
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+
+			reconcileResource()
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			degraded := meta.FindStatusCondition(resource.Status.Conditions, typeDegradedGenezioManager)
+			Expect(degraded).NotTo(BeNil())
+			Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+			Expect(degraded.Reason).To(Equal(reasonSecretKeyNotFound))
+
+			err := k8sClient.Get(ctx, typeNamespacedName, &appsv1.Deployment{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should reject a literal password together with a Secret reference", func() {
+			resource := &initv1alpha1.GenezioManager{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: initv1alpha1.GenezioManagerSpec{
+					GitConfig: giteaGitConfig(),
+					ContainerRegistryConfig: initv1alpha1.ContainerRegistryConfig{
+						URL:                "registry.example.com",
+						Username:           "genezio",
+						Password:           "plaintext",
+						PasswordSecretName: resourceName,
+						PasswordSecretKey:  "registry",
+					},
+					ContainerPort: 8080,
+				},
+			}
+			err := k8sClient.Create(ctx, resource)
+			Expect(errors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("password and passwordSecretName are mutually exclusive"))
+		})
+
+		It("should reject an unknown git provider", func() {
+			resource := &initv1alpha1.GenezioManager{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: initv1alpha1.GenezioManagerSpec{
+					GitConfig:     initv1alpha1.GitConfig{Provider: "svn", DeployementRepoName: "deployments"},
+					ContainerPort: 8080,
+				},
+			}
+			err := k8sClient.Create(ctx, resource)
+			Expect(errors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.gitConfig.provider"))
+		})
+
+		It("should report a missing deployment repository on GitReady and keep the Deployment", func() {
+			gitConfig := giteaGitConfig()
+			gitConfig.DeployementRepoName = "missing"
+			resource := &initv1alpha1.GenezioManager{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: initv1alpha1.GenezioManagerSpec{GitConfig: gitConfig, ContainerPort: 8080},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+
+			reconcileResource()
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			gitReady := meta.FindStatusCondition(resource.Status.Conditions, typeGitReadyGenezioManager)
+			Expect(gitReady).NotTo(BeNil())
+			Expect(gitReady.Status).To(Equal(metav1.ConditionFalse))
+			Expect(gitReady.Reason).To(Equal(reasonDeploymentRepositoryNotFound))
+			available := meta.FindStatusCondition(resource.Status.Conditions, typeAvailableGenezioManager)
+			Expect(available).NotTo(BeNil())
+			Expect(available.Status).To(Equal(metav1.ConditionFalse))
+			Expect(available.Reason).To(Equal(reasonDeploymentRepositoryNotFound))
+			Expect(resource.Status.DependenciesCheckTime).NotTo(BeNil())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &appsv1.Deployment{})).To(Succeed())
+		})
+	})
+
+	Context("When the spec of a reconciled resource changes", func() {
+		const resourceName = "test-drift"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		AfterEach(func() {
+			resource := &initv1alpha1.GenezioManager{}
+			if err := k8sClient.Get(ctx, typeNamespacedName, resource); err == nil {
+				resource.Finalizers = nil
+				Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+				Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			}
+			dep := &appsv1.Deployment{}
+			if err := k8sClient.Get(ctx, typeNamespacedName, dep); err == nil {
+				Expect(k8sClient.Delete(ctx, dep)).To(Succeed())
+			}
+		})
+
+		It("should converge the Deployment and revert manual edits", func() {
+			Expect(os.Setenv("GENEZIO_MANAGER_IMAGE", "example.com/genezio-manager:test")).To(Succeed())
+			controllerReconciler := &GenezioManagerReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			chartRevision := func() string {
+				dep := &appsv1.Deployment{}
+				Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+				for _, e := range dep.Spec.Template.Spec.Containers[0].Env {
+					if e.Name == "CHART_TARGET_REVISION" {
+						return e.Value
+					}
+				}
+				return ""
+			}
+
+			resource := &initv1alpha1.GenezioManager{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: initv1alpha1.GenezioManagerSpec{GitConfig: giteaGitConfig(), ChartRev: "v1", ContainerPort: 8080},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(chartRevision()).To(Equal("v1"))
+
+			By("bumping the chart revision")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.ChartRev = "v2"
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(chartRevision()).To(Equal("v2"))
+
+			By("editing an owned field of the Deployment by hand")
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			dep.Spec.Template.Spec.Containers[0].Image = "example.com/other:latest"
+			Expect(k8sClient.Update(ctx, dep)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal("example.com/genezio-manager:test"))
+		})
+
+		It("should not report Available before the Deployment has available replicas", func() {
+			Expect(os.Setenv("GENEZIO_MANAGER_IMAGE", "example.com/genezio-manager:test")).To(Succeed())
+			controllerReconciler := &GenezioManagerReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			resource := &initv1alpha1.GenezioManager{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: initv1alpha1.GenezioManagerSpec{GitConfig: giteaGitConfig(), ContainerPort: 8080},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.ObservedGeneration).To(Equal(resource.Generation))
+			Expect(resource.Status.Image).To(Equal("example.com/genezio-manager:test"))
+			Expect(resource.Status.Ready).To(Equal("0/1"))
+			Expect(meta.IsStatusConditionFalse(resource.Status.Conditions, typeAvailableGenezioManager)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, typeProgressingGenezioManager)).To(BeTrue())
+		})
+
+		It("should roll out the image overridden by the spec", func() {
+			Expect(os.Setenv("GENEZIO_MANAGER_IMAGE", "example.com/genezio-manager:test")).To(Succeed())
+			controllerReconciler := &GenezioManagerReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			digest := "sha256:" + strings.Repeat("ab", 32)
+			resource := &initv1alpha1.GenezioManager{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: initv1alpha1.GenezioManagerSpec{GitConfig: giteaGitConfig(), ContainerPort: 8080,
+					Image: &initv1alpha1.ImageConfig{
+						Repository: "example.com/mirror/genezio-manager",
+						Digest:     digest,
+						PullPolicy: corev1.PullIfNotPresent,
+					}},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			container := dep.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal("example.com/mirror/genezio-manager:test@" + digest))
+			Expect(container.ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+			Expect(dep.Labels).To(HaveKeyWithValue("app.kubernetes.io/version", "test"))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.ImageDigest).To(Equal(digest))
+		})
+	})
+
+	Context("When the resource is exposed", func() {
+		const resourceName = "test-networking"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		AfterEach(func() {
+			resource := &initv1alpha1.GenezioManager{}
+			if err := k8sClient.Get(ctx, typeNamespacedName, resource); err == nil {
+				resource.Finalizers = nil
+				Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+				Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			}
+			for _, obj := range []client.Object{&appsv1.Deployment{}, &corev1.Service{}, &networkingv1.Ingress{}} {
+				if err := k8sClient.Get(ctx, typeNamespacedName, obj); err == nil {
+					Expect(k8sClient.Delete(ctx, obj)).To(Succeed())
+				}
+			}
+		})
+
+		It("should create the Service and remove the Ingress once unset", func() {
+			Expect(os.Setenv("GENEZIO_MANAGER_IMAGE", "example.com/genezio-manager:test")).To(Succeed())
+			controllerReconciler := &GenezioManagerReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			resource := &initv1alpha1.GenezioManager{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: initv1alpha1.GenezioManagerSpec{
+					GitConfig:     giteaGitConfig(),
+					ContainerPort: 8080,
+					Service:       initv1alpha1.ServiceConfig{Type: corev1.ServiceTypeNodePort},
+					Ingress: &initv1alpha1.IngressConfig{
+						Host:          "manager.example.com",
+						TLSSecretName: "manager-tls",
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			svc := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+			Expect(svc.Spec.Type).To(Equal(corev1.ServiceTypeNodePort))
+			Expect(svc.Spec.Ports[0].Port).To(Equal(int32(8080)))
+
+			ing := &networkingv1.Ingress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, ing)).To(Succeed())
+			Expect(ing.Spec.Rules[0].Host).To(Equal("manager.example.com"))
+			Expect(ing.Spec.TLS[0].SecretName).To(Equal("manager-tls"))
+
+			By("removing the ingress block")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Ingress = nil
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Get(ctx, typeNamespacedName, &networkingv1.Ingress{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Context("When the deployment repository is declared to ArgoCD", func() {
+		const resourceName = "test-argocd-repository"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: defaultArgoCDNamespace}}
+			if err := k8sClient.Create(ctx, namespace); err != nil {
+				Expect(errors.IsAlreadyExists(err)).To(BeTrue())
+			}
+		})
+
+		AfterEach(func() {
+			resource := &initv1alpha1.GenezioManager{}
+			if err := k8sClient.Get(ctx, typeNamespacedName, resource); err == nil {
+				resource.Finalizers = nil
+				Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+				Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			}
+			dep := &appsv1.Deployment{}
+			if err := k8sClient.Get(ctx, typeNamespacedName, dep); err == nil {
+				Expect(k8sClient.Delete(ctx, dep)).To(Succeed())
+			}
+		})
+
+		It("should apply the repository Secret and delete it with the resource", func() {
+			Expect(os.Setenv("GENEZIO_MANAGER_IMAGE", "example.com/genezio-manager:test")).To(Succeed())
+			controllerReconciler := &GenezioManagerReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			resource := &initv1alpha1.GenezioManager{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: initv1alpha1.GenezioManagerSpec{
+					GitConfig:     giteaGitConfig(),
+					ArgoCDConfig:  initv1alpha1.ArgoCDConfig{Repository: &initv1alpha1.ArgoCDRepositoryConfig{}},
+					ContainerPort: 8080,
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			secretName := types.NamespacedName{Name: argoCDRepositorySecretName(resource), Namespace: defaultArgoCDNamespace}
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, secretName, secret)).To(Succeed())
+			Expect(secret.Labels[argoCDSecretTypeLabel]).To(Equal(argoCDSecretTypeRepository))
+			Expect(string(secret.Data["url"])).To(Equal("https://gitea.example.com/genezio/deployments.git"))
+			Expect(string(secret.Data["username"])).To(Equal("genezio"))
+			Expect(string(secret.Data["password"])).To(Equal("gitea-token"))
+
+			By("deleting the custom resource")
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			err = k8sClient.Get(ctx, secretName, &corev1.Secret{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
 })
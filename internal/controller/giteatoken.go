@@ -0,0 +1,239 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// giteaTokenSecretKey is the key of the minted access token in the managed Secret
+const giteaTokenSecretKey = "token"
+
+// Annotations of the managed Secret describing the minted access token
+const (
+	giteaTokenNameAnnotation      = "init.genezio.com/gitea-token-name"
+	giteaTokenUserAnnotation      = "init.genezio.com/gitea-token-user"
+	giteaTokenScopesAnnotation    = "init.genezio.com/gitea-token-scopes"
+	giteaTokenRotatedAtAnnotation = "init.genezio.com/gitea-token-rotated-at"
+)
+
+// defaultGiteaTokenScopes let the genezio-manager push to the deployment repository
+var defaultGiteaTokenScopes = []string{"write:repository", "read:user"}
+
+// usesMintedGiteaToken reports whether the operator mints the Gitea access token
+// from the password, which is the case when the spec gives a password but no token
+func usesMintedGiteaToken(gitConfig initv1alpha1.GitConfig) bool {
+	gitea := gitConfig.Gitea
+	return gitConfig.Provider == "gitea" && gitea.Token == "" && gitea.TokenSecretName == "" &&
+		(gitea.Password != "" || gitea.PasswordSecretName != "")
+}
+
+// giteaTokenSecretName returns the name of the Secret holding the minted access token
+func giteaTokenSecretName(name string) string {
+	return name + "-gitea-token"
+}
+
+// mintedGiteaTokenCredential references the minted access token from the managed Secret
+func mintedGiteaTokenCredential(name string) credential {
+	return credential{
+		path:       "spec.gitConfig.gitea.token",
+		secretName: giteaTokenSecretName(name),
+		secretKey:  giteaTokenSecretKey,
+	}
+}
+
+func giteaTokenScopes(gitConfig initv1alpha1.GitConfig) []string {
+	if len(gitConfig.Gitea.TokenScopes) > 0 {
+		return gitConfig.Gitea.TokenScopes
+	}
+	return defaultGiteaTokenScopes
+}
+
+// giteaTokenRotationDelay returns how long until the minted access token has to be
+// rotated, or zero when it is not rotated
+func giteaTokenRotationDelay(geneziomanager *initv1alpha1.GenezioManager, now time.Time) time.Duration {
+	interval := geneziomanager.Spec.GitConfig.Gitea.TokenRotationInterval
+	rotatedAt := geneziomanager.Status.GiteaTokenRotationTime
+	if !usesMintedGiteaToken(geneziomanager.Spec.GitConfig) || interval == nil || interval.Duration <= 0 ||
+		rotatedAt == nil {
+		return 0
+	}
+	if delay := rotatedAt.Add(interval.Duration).Sub(now); delay > time.Second {
+		return delay
+	}
+	return time.Second
+}
+
+// reconcileGiteaToken exchanges the Gitea password for an access token stored in a
+// Secret owned by the GenezioManager, so the password never reaches the operand. The
+// token is minted again when the user or the scopes change and once it is older
+// than the rotation interval, after which the previous token is revoked.
+func (r *GenezioManagerReconciler) reconcileGiteaToken(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) error {
+	log := log.FromContext(ctx)
+	gitConfig := geneziomanager.Spec.GitConfig
+	if !usesMintedGiteaToken(gitConfig) {
+		geneziomanager.Status.GiteaTokenRotationTime = nil
+		return nil
+	}
+
+	found := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: giteaTokenSecretName(geneziomanager.Name),
+		Namespace: geneziomanager.Namespace}, found)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	now := time.Now()
+	scopes := giteaTokenScopes(gitConfig)
+	rotatedAt, parseErr := time.Parse(time.RFC3339, found.Annotations[giteaTokenRotatedAtAnnotation])
+	interval := gitConfig.Gitea.TokenRotationInterval
+	due := !exists || len(found.Data[giteaTokenSecretKey]) == 0 || parseErr != nil ||
+		found.Annotations[giteaTokenUserAnnotation] != gitConfig.Gitea.Username ||
+		found.Annotations[giteaTokenScopesAnnotation] != strings.Join(scopes, ",") ||
+		(interval != nil && interval.Duration > 0 && !now.Before(rotatedAt.Add(interval.Duration)))
+	if !due {
+		geneziomanager.Status.GiteaTokenRotationTime = &metav1.Time{Time: rotatedAt}
+		return nil
+	}
+
+	password, err := r.resolveCredential(ctx, geneziomanager.Namespace, credentialsFor(geneziomanager).git.password)
+	if err != nil {
+		return err
+	}
+	auth := gitAuth{username: gitConfig.Gitea.Username, password: password}
+
+	// Token names are unique per user, the time of the rotation tells them apart
+	name := fmt.Sprintf("genezio-operator-%s-%s-%d", geneziomanager.Namespace, geneziomanager.Name, now.Unix())
+	token, err := giteaProvider{}.createToken(ctx, gitConfig, auth, name, scopes)
+	if errors.Is(err, errGitUnauthorized) {
+		return &specError{Reason: reasonGitUnauthorized,
+			Message: "gitea rejected the password of spec.gitConfig.gitea.username while minting an access token"}
+	} else if err != nil {
+		return fmt.Errorf("failed to mint a gitea access token: %w", err)
+	}
+
+	secret := &corev1.Secret{
+		// The type information is required by server-side apply
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      giteaTokenSecretName(geneziomanager.Name),
+			Namespace: geneziomanager.Namespace,
+			Labels:    selectorLabelsForGenezioManager(geneziomanager.Name),
+			Annotations: map[string]string{
+				giteaTokenNameAnnotation:      name,
+				giteaTokenUserAnnotation:      gitConfig.Gitea.Username,
+				giteaTokenScopesAnnotation:    strings.Join(scopes, ","),
+				giteaTokenRotatedAtAnnotation: now.UTC().Format(time.RFC3339),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{giteaTokenSecretKey: []byte(token)},
+	}
+	// Set the ownerRef for the Secret so it is garbage collected with the custom resource
+	if err := ctrl.SetControllerReference(geneziomanager, secret, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Patch(ctx, secret, client.Apply, client.ForceOwnership, client.FieldOwner(fieldManager)); err != nil {
+		log.Error(err, "Failed to apply Secret", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
+		return err
+	}
+	log.Info("Minted Gitea access token", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
+	geneziomanager.Status.GiteaTokenRotationTime = &metav1.Time{Time: now.UTC().Truncate(time.Second)}
+
+	// The previous token is revoked once the new one is stored. A failure only leaves
+	// an unused token behind, so it does not fail the reconciliation.
+	previous := found.Annotations[giteaTokenNameAnnotation]
+	if exists && previous != "" && found.Annotations[giteaTokenUserAnnotation] == gitConfig.Gitea.Username {
+		if err := (giteaProvider{}).deleteToken(ctx, gitConfig, auth, previous); err != nil {
+			log.Error(err, "Failed to revoke the previous Gitea access token", "token", previous)
+		}
+	}
+	return nil
+}
+
+// revokeGiteaToken revokes the access token recorded on the managed Secret when the
+// GenezioManager is deleted, so that no live credential is left behind in Gitea. The
+// Secret itself is garbage collected through its owner reference. Tokens which cannot
+// be revoked, e.g. because its password is gone or Gitea cannot be reached, are left
+// behind with a Warning event rather than blocking the deletion.
+func (r *GenezioManagerReconciler) revokeGiteaToken(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) error {
+	log := log.FromContext(ctx)
+	gitConfig := geneziomanager.Spec.GitConfig
+	found := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: giteaTokenSecretName(geneziomanager.Name),
+		Namespace: geneziomanager.Namespace}, found)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	name := found.Annotations[giteaTokenNameAnnotation]
+	if name == "" {
+		return nil
+	}
+	// Only the password of the user owning the token can revoke it
+	if !usesMintedGiteaToken(gitConfig) || found.Annotations[giteaTokenUserAnnotation] != gitConfig.Gitea.Username {
+		log.Info("Leaving the Gitea access token behind, the spec no longer holds the password of its user",
+			"token", name, "user", found.Annotations[giteaTokenUserAnnotation])
+		return nil
+	}
+	password, err := r.resolveCredential(ctx, geneziomanager.Namespace, credentialsFor(geneziomanager).git.password)
+	var specErr *specError
+	if errors.As(err, &specErr) || apierrors.IsNotFound(err) {
+		log.Info("Leaving the Gitea access token behind, the password of its user cannot be read",
+			"token", name, "error", err.Error())
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	auth := gitAuth{username: gitConfig.Gitea.Username, password: password}
+	err = giteaProvider{}.deleteToken(ctx, gitConfig, auth, name)
+	if errors.Is(err, errGitUnauthorized) {
+		log.Info("Leaving the Gitea access token behind, gitea rejected the password of its user", "token", name)
+		r.recordWarning(geneziomanager, reasonTokenRevocationFailed, fmt.Sprintf(
+			"Left the Gitea access token %s behind, gitea rejected the password of %s", name, gitConfig.Gitea.Username))
+		return nil
+	} else if err != nil {
+		log.Info("Leaving the Gitea access token behind, it could not be revoked", "token", name, "error", err.Error())
+		r.recordWarning(geneziomanager, reasonTokenRevocationFailed, fmt.Sprintf(
+			"Left the Gitea access token %s behind, it could not be revoked: %s", name, err))
+		return nil
+	}
+	log.Info("Revoked Gitea access token", "token", name)
+	return nil
+}
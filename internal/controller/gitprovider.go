@@ -0,0 +1,330 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Reasons used on the Degraded condition when the git configuration is invalid
+const (
+	reasonInvalidGitConfig             = "InvalidGitConfig"
+	reasonUnknownGitProvider           = "UnknownGitProvider"
+	reasonGitUnauthorized              = "GitUnauthorized"
+	reasonDeploymentRepositoryNotFound = "DeploymentRepositoryNotFound"
+)
+
+// gitCAMountPath is where the CA bundle of the git provider is mounted in the operand
+const gitCAMountPath = "/etc/genezio/git-ca"
+
+// gitCAFileName is the name of the CA bundle file inside gitCAMountPath
+const gitCAFileName = "ca.crt"
+
+// gitAPITimeout bounds every call made by the operator to the API of a git provider
+const gitAPITimeout = 10 * time.Second
+
+var (
+	// errGitUnauthorized is returned when the git provider rejects the credentials
+	errGitUnauthorized = errors.New("the git provider rejected the credentials")
+	// errGitRepositoryNotFound is returned when the deployment repository does not exist
+	errGitRepositoryNotFound = errors.New("the deployment repository does not exist")
+)
+
+// gitCredentials holds the credentials found in the block of a git provider
+type gitCredentials struct {
+	username string
+	password credential
+	token    credential
+	// ca is the CA bundle used to verify the certificate of the git provider
+	ca credential
+}
+
+// gitAuth holds the resolved credentials used by the operator to call the API of a git provider
+type gitAuth struct {
+	username string
+	password string
+	token    string
+	caBundle []byte
+}
+
+// gitRepository describes the deployment repository as reported by the git provider
+type gitRepository struct {
+	CloneURL      string `json:"clone_url"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+// gitOperandConfig is the configuration of the genezio-manager container for a git provider
+type gitOperandConfig struct {
+	// repoName overrides the name of the deployment repository passed to the operand
+	repoName     string
+	env          []corev1.EnvVar
+	volumes      []corev1.Volume
+	volumeMounts []corev1.VolumeMount
+}
+
+// merge appends the environment and volumes of another configuration
+func (c gitOperandConfig) merge(other gitOperandConfig) gitOperandConfig {
+	c.env = append(c.env, other.env...)
+	c.volumes = append(c.volumes, other.volumes...)
+	c.volumeMounts = append(c.volumeMounts, other.volumeMounts...)
+	return c
+}
+
+// gitProvider integrates the operator with a git hosting service. Every provider
+// lives in its own gitprovider_<name>.go file and registers itself on init.
+type gitProvider interface {
+	// Credentials returns the credentials held by the block of the provider
+	Credentials(gitConfig initv1alpha1.GitConfig) gitCredentials
+	// Render returns the environment and volumes of the genezio-manager container
+	Render(gitConfig initv1alpha1.GitConfig, creds gitCredentials) gitOperandConfig
+	// CheckConnectivity authenticates against the API of the provider
+	CheckConnectivity(ctx context.Context, gitConfig initv1alpha1.GitConfig, auth gitAuth) error
+	// EnsureRepository makes sure the deployment repository exists and describes it
+	EnsureRepository(ctx context.Context, gitConfig initv1alpha1.GitConfig, auth gitAuth) (gitRepository, error)
+}
+
+// gitProviders holds the registered git providers by the value of spec.gitConfig.provider
+var gitProviders = map[string]gitProvider{}
+
+func registerGitProvider(name string, provider gitProvider) {
+	gitProviders[name] = provider
+}
+
+// gitProviderFor returns the git provider selected by the git configuration
+func gitProviderFor(gitConfig initv1alpha1.GitConfig) (gitProvider, error) {
+	provider, ok := gitProviders[gitConfig.Provider]
+	if !ok {
+		names := make([]string, 0, len(gitProviders))
+		for name := range gitProviders {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, &specError{Reason: reasonUnknownGitProvider,
+			Message: fmt.Sprintf("spec.gitConfig.provider %q is not supported, use one of %s",
+				gitConfig.Provider, strings.Join(names, ", "))}
+	}
+	return provider, nil
+}
+
+// validateGitConfig checks that the block of the selected git provider holds
+// everything the genezio-manager needs to push to the deployment repository.
+func validateGitConfig(gitConfig initv1alpha1.GitConfig) error {
+	if _, err := gitProviderFor(gitConfig); err != nil {
+		return err
+	}
+	return specErrorFor(reasonInvalidGitConfig,
+		initv1alpha1.ValidateGitConfig(gitConfig, field.NewPath("spec", "gitConfig")))
+}
+
+// ensureDeploymentRepository authenticates against the git provider, makes sure the
+// deployment repository exists, creating it if the provider is asked to, and records
+// it in the status. Every failure is reported on the GitReady condition.
+func (r *GenezioManagerReconciler) ensureDeploymentRepository(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) error {
+	gitConfig := geneziomanager.Spec.GitConfig
+	provider, err := gitProviderFor(gitConfig)
+	if err != nil {
+		setGitReadyCondition(geneziomanager, err)
+		return err
+	}
+	auth, err := r.gitAuthFor(ctx, geneziomanager)
+	if err != nil {
+		setGitReadyCondition(geneziomanager, err)
+		return err
+	}
+
+	// Authenticating first tells credential and connectivity problems apart from
+	// a missing or unreadable deployment repository
+	if err := provider.CheckConnectivity(ctx, gitConfig, auth); err != nil {
+		if errors.Is(err, errGitUnauthorized) {
+			err = &specError{Reason: reasonGitUnauthorized,
+				Message: fmt.Sprintf("the %s provider rejected the credentials of spec.gitConfig", gitConfig.Provider)}
+		} else {
+			err = fmt.Errorf("failed to reach the %s provider: %w", gitConfig.Provider, err)
+		}
+		setGitReadyCondition(geneziomanager, err)
+		return err
+	}
+
+	repo, err := provider.EnsureRepository(ctx, gitConfig, auth)
+	switch {
+	case errors.Is(err, errGitUnauthorized):
+		err = &specError{Reason: reasonGitUnauthorized,
+			Message: fmt.Sprintf("the credentials of spec.gitConfig have no access to the deployment repository %s",
+				gitConfig.DeployementRepoName)}
+	case errors.Is(err, errGitRepositoryNotFound):
+		err = &specError{Reason: reasonDeploymentRepositoryNotFound,
+			Message: fmt.Sprintf("the deployment repository %s does not exist on the %s provider",
+				gitConfig.DeployementRepoName, gitConfig.Provider)}
+	case err != nil:
+		err = fmt.Errorf("failed to ensure the deployment repository: %w", err)
+	}
+	setGitReadyCondition(geneziomanager, err)
+	if err != nil {
+		return err
+	}
+
+	geneziomanager.Status.DeploymentRepository = &initv1alpha1.DeploymentRepositoryStatus{
+		CloneURL:      repo.CloneURL,
+		DefaultBranch: repo.DefaultBranch,
+	}
+	return nil
+}
+
+// deploymentRepositoryKnown reports whether the clone URL of the deployment repository
+// is known, either from the SSH configuration or from the lookup on the git provider
+func deploymentRepositoryKnown(geneziomanager *initv1alpha1.GenezioManager) bool {
+	repo := geneziomanager.Status.DeploymentRepository
+	return geneziomanager.Spec.GitConfig.SSH != nil || (repo != nil && repo.CloneURL != "")
+}
+
+// renderGitOperandConfig renders the GIT_* contract of the genezio-manager container
+// shared by every provider, and mounts the CA bundle when one is referenced.
+func renderGitOperandConfig(url, owner string, creds gitCredentials) gitOperandConfig {
+	config := gitOperandConfig{
+		env: []corev1.EnvVar{
+			{
+				Name:  "GIT_OWNER",
+				Value: owner,
+			},
+			{
+				Name:  "GIT_USER",
+				Value: creds.username,
+			},
+			{
+				Name:  "GIT_URL",
+				Value: url,
+			},
+			creds.password.envVar("GIT_PASSWORD"),
+			creds.token.envVar("GIT_TOKEN"),
+		},
+	}
+
+	if creds.ca.secretName != "" {
+		config.volumes = append(config.volumes, corev1.Volume{
+			Name: "git-ca",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  creds.ca.secretName,
+					Items:       []corev1.KeyToPath{{Key: creds.ca.secretKey, Path: gitCAFileName}},
+					DefaultMode: &[]int32{0444}[0],
+				},
+			},
+		})
+		config.volumeMounts = append(config.volumeMounts, corev1.VolumeMount{
+			Name:      "git-ca",
+			MountPath: gitCAMountPath,
+			ReadOnly:  true,
+		})
+		// An empty GIT_SSL_CAINFO would make git fail, so it is only set with a CA bundle
+		config.env = append(config.env, corev1.EnvVar{
+			Name:  "GIT_SSL_CAINFO",
+			Value: gitCAMountPath + "/" + gitCAFileName,
+		})
+	}
+	return config
+}
+
+// gitHTTPClient returns the client used to call the API of a git provider
+func gitHTTPClient(auth gitAuth) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(auth.caBundle) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(auth.caBundle) {
+			return nil, fmt.Errorf("the CA bundle of the git provider holds no PEM certificate")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return &http.Client{Transport: transport, Timeout: gitAPITimeout}, nil
+}
+
+// gitAPIGet performs an authenticated GET against the API of a git provider and
+// decodes the JSON response into out, if given. 401 and 403 are mapped to
+// errGitUnauthorized and 404 to notFound.
+func gitAPIGet(ctx context.Context, auth gitAuth, endpoint string,
+	authenticate func(*http.Request), notFound error, out interface{}) error {
+	return gitAPIDo(ctx, auth, http.MethodGet, endpoint, nil, authenticate, notFound, out)
+}
+
+// gitAPIDo performs an authenticated request against the API of a git provider,
+// sending in as JSON and decoding the JSON response into out, if given.
+func gitAPIDo(ctx context.Context, auth gitAuth, method, endpoint string, in interface{},
+	authenticate func(*http.Request), notFound error, out interface{}) error {
+	httpClient, err := gitHTTPClient(auth)
+	if err != nil {
+		return err
+	}
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	authenticate(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return errGitUnauthorized
+	case resp.StatusCode == http.StatusNotFound && notFound != nil:
+		return notFound
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("%s %s returned %s", method, endpoint, resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// apiURL joins the base URL of a provider with the path of an API endpoint
+func apiURL(base string, path string) string {
+	return strings.TrimSuffix(base, "/") + path
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+)
+
+func init() {
+	registerGitProvider("bitbucket", bitbucketProvider{})
+}
+
+// bitbucketProvider integrates the operator with Bitbucket Server and Data Center
+type bitbucketProvider struct{}
+
+// repoSlug returns the slug of the deployment repository
+func (bitbucketProvider) repoSlug(gitConfig initv1alpha1.GitConfig) string {
+	if gitConfig.Bitbucket.RepoSlug != "" {
+		return gitConfig.Bitbucket.RepoSlug
+	}
+	return gitConfig.DeployementRepoName
+}
+
+func (bitbucketProvider) Credentials(gitConfig initv1alpha1.GitConfig) gitCredentials {
+	bitbucket := gitConfig.Bitbucket
+	return gitCredentials{
+		username: bitbucket.Username,
+		token: credential{
+			path:       "spec.gitConfig.bitbucket.token",
+			value:      bitbucket.Token,
+			secretName: bitbucket.TokenSecretName,
+			secretKey:  bitbucket.TokenSecretKey,
+		},
+	}
+}
+
+// Render passes the project key as the owner and the slug as the name of the deployment repository
+func (p bitbucketProvider) Render(gitConfig initv1alpha1.GitConfig, creds gitCredentials) gitOperandConfig {
+	config := renderGitOperandConfig(gitConfig.Bitbucket.URL, gitConfig.Bitbucket.ProjectKey, creds)
+	config.repoName = p.repoSlug(gitConfig)
+	return config
+}
+
+// authenticate uses the HTTP access token as a bearer token
+func (bitbucketProvider) authenticate(auth gitAuth) func(*http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+auth.token)
+	}
+}
+
+// CheckConnectivity reads the project, which requires valid credentials with access to it
+func (p bitbucketProvider) CheckConnectivity(ctx context.Context, gitConfig initv1alpha1.GitConfig, auth gitAuth) error {
+	endpoint := apiURL(gitConfig.Bitbucket.URL,
+		fmt.Sprintf("/rest/api/1.0/projects/%s", url.PathEscape(gitConfig.Bitbucket.ProjectKey)))
+	return gitAPIGet(ctx, auth, endpoint, p.authenticate(auth), nil, nil)
+}
+
+// EnsureRepository verifies that the deployment repository exists in the project
+// and reads its HTTP clone link and default branch
+func (p bitbucketProvider) EnsureRepository(ctx context.Context, gitConfig initv1alpha1.GitConfig,
+	auth gitAuth) (gitRepository, error) {
+	repoURL := apiURL(gitConfig.Bitbucket.URL, fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s",
+		url.PathEscape(gitConfig.Bitbucket.ProjectKey), url.PathEscape(p.repoSlug(gitConfig))))
+	repo := struct {
+		Links struct {
+			Clone []struct {
+				Href string `json:"href"`
+				Name string `json:"name"`
+			} `json:"clone"`
+		} `json:"links"`
+	}{}
+	if err := gitAPIGet(ctx, auth, repoURL, p.authenticate(auth), errGitRepositoryNotFound, &repo); err != nil {
+		return gitRepository{}, err
+	}
+
+	result := gitRepository{}
+	for _, link := range repo.Links.Clone {
+		if link.Name == "http" {
+			result.CloneURL = link.Href
+		}
+	}
+
+	// Empty repositories have no default branch yet
+	branch := struct {
+		DisplayID string `json:"displayId"`
+	}{}
+	if err := gitAPIGet(ctx, auth, repoURL+"/default-branch", p.authenticate(auth), errGitRepositoryNotFound,
+		&branch); err != nil && !errors.Is(err, errGitRepositoryNotFound) {
+		return gitRepository{}, err
+	}
+	result.DefaultBranch = branch.DisplayID
+	return result, nil
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+)
+
+func init() {
+	registerGitProvider("gitea", giteaProvider{})
+}
+
+// giteaProvider integrates the operator with a Gitea instance
+type giteaProvider struct{}
+
+func (giteaProvider) Credentials(gitConfig initv1alpha1.GitConfig) gitCredentials {
+	gitea := gitConfig.Gitea
+	return gitCredentials{
+		username: gitea.Username,
+		password: credential{
+			path:       "spec.gitConfig.gitea.password",
+			value:      gitea.Password,
+			secretName: gitea.PasswordSecretName,
+			secretKey:  gitea.PasswordSecretKey,
+		},
+		token: credential{
+			path:       "spec.gitConfig.gitea.token",
+			value:      gitea.Token,
+			secretName: gitea.TokenSecretName,
+			secretKey:  gitea.TokenSecretKey,
+		},
+	}
+}
+
+func (giteaProvider) Render(gitConfig initv1alpha1.GitConfig, creds gitCredentials) gitOperandConfig {
+	return renderGitOperandConfig(gitConfig.Gitea.URL, "", creds)
+}
+
+// authenticate prefers the token and falls back to basic authentication
+func (giteaProvider) authenticate(auth gitAuth) func(*http.Request) {
+	return func(req *http.Request) {
+		if auth.token != "" {
+			req.Header.Set("Authorization", "token "+auth.token)
+			return
+		}
+		req.SetBasicAuth(auth.username, auth.password)
+	}
+}
+
+func (p giteaProvider) CheckConnectivity(ctx context.Context, gitConfig initv1alpha1.GitConfig, auth gitAuth) error {
+	return gitAPIGet(ctx, auth, apiURL(gitConfig.Gitea.URL, "/api/v1/user"), p.authenticate(auth), nil, nil)
+}
+
+// giteaDefaultBranch is the default branch of a deployment repository created by the operator
+const giteaDefaultBranch = "main"
+
+// errGiteaTokenNotFound is returned when the access token to revoke does not exist
+var errGiteaTokenNotFound = errors.New("the gitea access token does not exist")
+
+// giteaSkeletonFiles are committed to a deployment repository created by the operator
+var giteaSkeletonFiles = map[string]string{
+	"README.md": "# Deployments\n\nThis repository is managed by the genezio-manager. " +
+		"Every application is deployed from a directory under apps/.\n",
+	"apps/.gitkeep": "",
+}
+
+// EnsureRepository verifies that the deployment repository exists under the account
+// of the user and creates it when spec.gitConfig.gitea.createRepository is set. The
+// skeleton is committed in a second call, so it is also committed to an existing
+// repository which is still empty, e.g. when that call failed on an earlier pass.
+func (p giteaProvider) EnsureRepository(ctx context.Context, gitConfig initv1alpha1.GitConfig,
+	auth gitAuth) (gitRepository, error) {
+	endpoint := apiURL(gitConfig.Gitea.URL, fmt.Sprintf("/api/v1/repos/%s/%s",
+		url.PathEscape(gitConfig.Gitea.Username), url.PathEscape(gitConfig.DeployementRepoName)))
+	existing := struct {
+		gitRepository
+		Empty bool `json:"empty"`
+	}{}
+	err := gitAPIGet(ctx, auth, endpoint, p.authenticate(auth), errGitRepositoryNotFound, &existing)
+	if !gitConfig.Gitea.CreateRepository || (err == nil && !existing.Empty) ||
+		(err != nil && !errors.Is(err, errGitRepositoryNotFound)) {
+		return existing.gitRepository, err
+	}
+
+	branch := gitConfig.Gitea.DefaultBranch
+	if branch == "" {
+		branch = giteaDefaultBranch
+	}
+	repo := existing.gitRepository
+	if err == nil {
+		// The repository has no commit yet, its default branch is created by the skeleton
+		if repo.DefaultBranch != "" {
+			branch = repo.DefaultBranch
+		}
+	} else {
+		create := map[string]interface{}{
+			"name":           gitConfig.DeployementRepoName,
+			"private":        true,
+			"default_branch": branch,
+			"auto_init":      false,
+		}
+		if err := gitAPIDo(ctx, auth, http.MethodPost, apiURL(gitConfig.Gitea.URL, "/api/v1/user/repos"),
+			create, p.authenticate(auth), nil, &repo); err != nil {
+			return gitRepository{}, fmt.Errorf("failed to create the deployment repository: %w", err)
+		}
+	}
+
+	// The initial commit lays out the skeleton expected by the genezio-manager
+	paths := make([]string, 0, len(giteaSkeletonFiles))
+	for path := range giteaSkeletonFiles {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	files := make([]map[string]string, 0, len(paths))
+	for _, path := range paths {
+		files = append(files, map[string]string{
+			"operation": "create",
+			"path":      path,
+			"content":   base64.StdEncoding.EncodeToString([]byte(giteaSkeletonFiles[path])),
+		})
+	}
+	commit := map[string]interface{}{
+		"branch":  branch,
+		"message": "Initialize the deployment repository",
+		"files":   files,
+	}
+	if err := gitAPIDo(ctx, auth, http.MethodPost, endpoint+"/contents", commit,
+		p.authenticate(auth), nil, nil); err != nil {
+		return gitRepository{}, fmt.Errorf("failed to commit the skeleton of the deployment repository: %w", err)
+	}
+	repo.DefaultBranch = branch
+	return repo, nil
+}
+
+// createToken mints an access token of the user with the given scopes. Gitea only
+// accepts basic authentication for this endpoint.
+func (giteaProvider) createToken(ctx context.Context, gitConfig initv1alpha1.GitConfig, auth gitAuth,
+	name string, scopes []string) (string, error) {
+	endpoint := apiURL(gitConfig.Gitea.URL,
+		fmt.Sprintf("/api/v1/users/%s/tokens", url.PathEscape(gitConfig.Gitea.Username)))
+	token := struct {
+		SHA1 string `json:"sha1"`
+	}{}
+	basicAuth := func(req *http.Request) { req.SetBasicAuth(auth.username, auth.password) }
+	if err := gitAPIDo(ctx, auth, http.MethodPost, endpoint, map[string]interface{}{"name": name, "scopes": scopes},
+		basicAuth, nil, &token); err != nil {
+		return "", err
+	}
+	if token.SHA1 == "" {
+		return "", fmt.Errorf("gitea returned an empty access token")
+	}
+	return token.SHA1, nil
+}
+
+// deleteToken revokes an access token of the user, succeeding if it is already gone
+func (giteaProvider) deleteToken(ctx context.Context, gitConfig initv1alpha1.GitConfig, auth gitAuth,
+	name string) error {
+	endpoint := apiURL(gitConfig.Gitea.URL, fmt.Sprintf("/api/v1/users/%s/tokens/%s",
+		url.PathEscape(gitConfig.Gitea.Username), url.PathEscape(name)))
+	basicAuth := func(req *http.Request) { req.SetBasicAuth(auth.username, auth.password) }
+	err := gitAPIDo(ctx, auth, http.MethodDelete, endpoint, nil, basicAuth, errGiteaTokenNotFound, nil)
+	if errors.Is(err, errGiteaTokenNotFound) {
+		return nil
+	}
+	return err
+}
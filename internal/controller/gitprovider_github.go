@@ -0,0 +1,108 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultGitHubAPIURL is the API of github.com, used when no GitHub Enterprise URL is given
+const defaultGitHubAPIURL = "https://api.github.com"
+
+func init() {
+	registerGitProvider("github", githubProvider{})
+}
+
+// githubProvider integrates the operator with GitHub and GitHub Enterprise
+type githubProvider struct{}
+
+func (githubProvider) apiURL(gitConfig initv1alpha1.GitConfig) string {
+	if gitConfig.GitHub.APIURL != "" {
+		return gitConfig.GitHub.APIURL
+	}
+	return defaultGitHubAPIURL
+}
+
+// hostURL returns the URL of the git host serving the repositories, which is not the
+// API: https://github.com for api.github.com, the API URL without its /api/v3 suffix
+// for GitHub Enterprise
+func (p githubProvider) hostURL(gitConfig initv1alpha1.GitConfig) string {
+	api := strings.TrimSuffix(p.apiURL(gitConfig), "/")
+	u, err := url.Parse(api)
+	if err != nil {
+		return api
+	}
+	if u.Host == "api.github.com" {
+		return u.Scheme + "://github.com"
+	}
+	return strings.TrimSuffix(api, "/api/v3")
+}
+
+func (githubProvider) Credentials(gitConfig initv1alpha1.GitConfig) gitCredentials {
+	github := gitConfig.GitHub
+	username := github.Username
+	if username == "" {
+		// GitHub accepts any username together with a token over HTTPS
+		username = "x-access-token"
+	}
+	return gitCredentials{
+		username: username,
+		token: credential{
+			path:       "spec.gitConfig.github.token",
+			value:      github.Token,
+			secretName: github.TokenSecretName,
+			secretKey:  github.TokenSecretKey,
+		},
+	}
+}
+
+func (p githubProvider) Render(gitConfig initv1alpha1.GitConfig, creds gitCredentials) gitOperandConfig {
+	config := renderGitOperandConfig(p.hostURL(gitConfig), gitConfig.GitHub.Owner, creds)
+	config.env = append(config.env, corev1.EnvVar{
+		Name:  "GIT_API_URL",
+		Value: p.apiURL(gitConfig),
+	})
+	return config
+}
+
+func (githubProvider) authenticate(auth gitAuth) func(*http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+auth.token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+	}
+}
+
+func (p githubProvider) CheckConnectivity(ctx context.Context, gitConfig initv1alpha1.GitConfig, auth gitAuth) error {
+	return gitAPIGet(ctx, auth, apiURL(p.apiURL(gitConfig), "/user"), p.authenticate(auth), nil, nil)
+}
+
+// EnsureRepository verifies that the deployment repository exists under the owner
+func (p githubProvider) EnsureRepository(ctx context.Context, gitConfig initv1alpha1.GitConfig,
+	auth gitAuth) (gitRepository, error) {
+	endpoint := apiURL(p.apiURL(gitConfig), fmt.Sprintf("/repos/%s/%s",
+		url.PathEscape(gitConfig.GitHub.Owner), url.PathEscape(gitConfig.DeployementRepoName)))
+	repo := gitRepository{}
+	err := gitAPIGet(ctx, auth, endpoint, p.authenticate(auth), errGitRepositoryNotFound, &repo)
+	return repo, err
+}
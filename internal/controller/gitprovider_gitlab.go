@@ -0,0 +1,98 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+)
+
+func init() {
+	registerGitProvider("gitlab", gitlabProvider{})
+}
+
+// gitlabProvider integrates the operator with a self-managed GitLab instance
+type gitlabProvider struct{}
+
+func (gitlabProvider) Credentials(gitConfig initv1alpha1.GitConfig) gitCredentials {
+	gitlab := gitConfig.GitLab
+	username := gitlab.Username
+	if username == "" {
+		// Project and group access tokens accept any non-empty username
+		username = "oauth2"
+	}
+	return gitCredentials{
+		username: username,
+		token: credential{
+			path:       "spec.gitConfig.gitlab.token",
+			value:      gitlab.Token,
+			secretName: gitlab.TokenSecretName,
+			secretKey:  gitlab.TokenSecretKey,
+		},
+		ca: credential{
+			path:       "spec.gitConfig.gitlab.ca",
+			secretName: gitlab.CASecretName,
+			secretKey:  gitlab.CASecretKey,
+		},
+	}
+}
+
+// Render passes the group path as the owner, the deployment repository lives under it
+func (gitlabProvider) Render(gitConfig initv1alpha1.GitConfig, creds gitCredentials) gitOperandConfig {
+	return renderGitOperandConfig(gitConfig.GitLab.URL, gitConfig.GitLab.Namespace, creds)
+}
+
+func (gitlabProvider) authenticate(auth gitAuth) func(*http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set("PRIVATE-TOKEN", auth.token)
+	}
+}
+
+func (p gitlabProvider) CheckConnectivity(ctx context.Context, gitConfig initv1alpha1.GitConfig, auth gitAuth) error {
+	return gitAPIGet(ctx, auth, apiURL(gitConfig.GitLab.URL, "/api/v4/user"), p.authenticate(auth), nil, nil)
+}
+
+// EnsureRepository verifies that the deployment project exists in the namespace,
+// which defaults to the personal namespace of the owner of the token
+func (p gitlabProvider) EnsureRepository(ctx context.Context, gitConfig initv1alpha1.GitConfig,
+	auth gitAuth) (gitRepository, error) {
+	namespace := gitConfig.GitLab.Namespace
+	if namespace == "" {
+		user := struct {
+			Username string `json:"username"`
+		}{}
+		if err := gitAPIGet(ctx, auth, apiURL(gitConfig.GitLab.URL, "/api/v4/user"),
+			p.authenticate(auth), nil, &user); err != nil {
+			return gitRepository{}, err
+		}
+		namespace = user.Username
+	}
+
+	project := struct {
+		HTTPURLToRepo string `json:"http_url_to_repo"`
+		DefaultBranch string `json:"default_branch"`
+	}{}
+	endpoint := apiURL(gitConfig.GitLab.URL,
+		"/api/v4/projects/"+url.PathEscape(namespace+"/"+gitConfig.DeployementRepoName))
+	if err := gitAPIGet(ctx, auth, endpoint, p.authenticate(auth), errGitRepositoryNotFound, &project); err != nil {
+		return gitRepository{}, err
+	}
+	return gitRepository{CloneURL: project.HTTPURLToRepo, DefaultBranch: project.DefaultBranch}, nil
+}
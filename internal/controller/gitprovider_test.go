@@ -0,0 +1,449 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The git providers only talk HTTP, so they are tested against an httptest
+// stand-in of their API and do not need the envtest control plane.
+
+func TestGitProviderForUnknownProvider(t *testing.T) {
+	_, err := gitProviderFor(initv1alpha1.GitConfig{Provider: "svn"})
+	var specErr *specError
+	if !errors.As(err, &specErr) || specErr.Reason != reasonUnknownGitProvider {
+		t.Fatalf("expected an %s error, got %v", reasonUnknownGitProvider, err)
+	}
+}
+
+func TestCheckSpecUnknownGitProvider(t *testing.T) {
+	geneziomanager := &initv1alpha1.GenezioManager{
+		ObjectMeta: metav1.ObjectMeta{Name: "manager", Namespace: "default"},
+		Spec: initv1alpha1.GenezioManagerSpec{
+			GitConfig: initv1alpha1.GitConfig{Provider: "svn", DeployementRepoName: "deployments"},
+		},
+	}
+	r := &GenezioManagerReconciler{}
+	expectDegraded(t, geneziomanager, r.checkSpec(context.Background(), geneziomanager), reasonUnknownGitProvider)
+}
+
+func TestGiteaProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/user":
+			_, _ = w.Write([]byte(`{"login":"genezio"}`))
+		case "/api/v1/repos/genezio/deployments":
+			_, _ = w.Write([]byte(`{"clone_url":"https://gitea.example.com/genezio/deployments.git",` +
+				`"default_branch":"main"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	provider := gitProviders["gitea"]
+	gitConfig := initv1alpha1.GitConfig{
+		Provider:            "gitea",
+		DeployementRepoName: "deployments",
+		Gitea:               initv1alpha1.GiteaProvider{URL: server.URL, Username: "genezio"},
+	}
+
+	if err := provider.CheckConnectivity(ctx, gitConfig, gitAuth{token: "secret"}); err != nil {
+		t.Fatalf("unexpected error checking connectivity: %v", err)
+	}
+	if err := provider.CheckConnectivity(ctx, gitConfig, gitAuth{token: "wrong"}); !errors.Is(err, errGitUnauthorized) {
+		t.Fatalf("expected errGitUnauthorized, got %v", err)
+	}
+	repo, err := provider.EnsureRepository(ctx, gitConfig, gitAuth{token: "secret"})
+	if err != nil {
+		t.Fatalf("unexpected error ensuring the repository: %v", err)
+	}
+	if repo.CloneURL != "https://gitea.example.com/genezio/deployments.git" || repo.DefaultBranch != "main" {
+		t.Fatalf("unexpected repository %+v", repo)
+	}
+
+	gitConfig.DeployementRepoName = "missing"
+	if _, err := provider.EnsureRepository(ctx, gitConfig, gitAuth{token: "secret"}); !errors.Is(err, errGitRepositoryNotFound) {
+		t.Fatalf("expected errGitRepositoryNotFound, got %v", err)
+	}
+}
+
+func TestEnsureDeploymentRepository(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("Authorization") != "token secret" && r.Header.Get("Authorization") != "token reader":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/api/v1/user":
+			_, _ = w.Write([]byte(`{"login":"genezio"}`))
+		case r.Header.Get("Authorization") == "token reader":
+			// A token without access to the repository still authenticates
+			w.WriteHeader(http.StatusForbidden)
+		case r.URL.Path == "/api/v1/repos/genezio/deployments":
+			_, _ = w.Write([]byte(`{"clone_url":"https://gitea.example.com/genezio/deployments.git"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	// Inline credentials are resolved without reading Secrets
+	r := &GenezioManagerReconciler{}
+	for _, tc := range []struct {
+		url, token, repo string
+		condition        metav1.ConditionStatus
+		reason           string
+	}{
+		{server.URL, "secret", "deployments", metav1.ConditionTrue, reasonGitRepositoryFound},
+		{server.URL, "wrong", "deployments", metav1.ConditionFalse, reasonGitUnauthorized},
+		{server.URL, "reader", "deployments", metav1.ConditionFalse, reasonGitUnauthorized},
+		{server.URL, "secret", "missing", metav1.ConditionFalse, reasonDeploymentRepositoryNotFound},
+		{"http://127.0.0.1:1", "secret", "deployments", metav1.ConditionFalse, reasonGitUnreachable},
+	} {
+		geneziomanager := &initv1alpha1.GenezioManager{Spec: initv1alpha1.GenezioManagerSpec{
+			GitConfig: initv1alpha1.GitConfig{
+				Provider:            "gitea",
+				DeployementRepoName: tc.repo,
+				Gitea:               initv1alpha1.GiteaProvider{URL: tc.url, Username: "genezio", Token: tc.token},
+			},
+		}}
+		err := r.ensureDeploymentRepository(context.Background(), geneziomanager)
+		if (err == nil) != (tc.condition == metav1.ConditionTrue) {
+			t.Errorf("%s with token %s: unexpected error %v", tc.repo, tc.token, err)
+		}
+		condition := meta.FindStatusCondition(geneziomanager.Status.Conditions, typeGitReadyGenezioManager)
+		if condition == nil || condition.Status != tc.condition || condition.Reason != tc.reason {
+			t.Errorf("%s with token %s: expected GitReady %s with reason %s, got %v",
+				tc.repo, tc.token, tc.condition, tc.reason, condition)
+		}
+	}
+}
+
+func TestGiteaProviderCreateRepository(t *testing.T) {
+	created := map[string]interface{}{}
+	commit := map[string]interface{}{}
+	existing := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/repos/genezio/deployments" && existing != "":
+			_, _ = w.Write([]byte(existing))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/user/repos":
+			_ = json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"clone_url":"https://gitea.example.com/genezio/deployments.git"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/repos/genezio/deployments/contents":
+			_ = json.NewDecoder(r.Body).Decode(&commit)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	provider := gitProviders["gitea"]
+	gitConfig := initv1alpha1.GitConfig{
+		Provider:            "gitea",
+		DeployementRepoName: "deployments",
+		Gitea:               initv1alpha1.GiteaProvider{URL: server.URL, Username: "genezio"},
+	}
+
+	if _, err := provider.EnsureRepository(ctx, gitConfig, gitAuth{token: "secret"}); !errors.Is(err, errGitRepositoryNotFound) {
+		t.Fatalf("expected the repository not to be created without createRepository, got %v", err)
+	}
+
+	gitConfig.Gitea.CreateRepository = true
+	gitConfig.Gitea.DefaultBranch = "trunk"
+	repo, err := provider.EnsureRepository(ctx, gitConfig, gitAuth{token: "secret"})
+	if err != nil {
+		t.Fatalf("unexpected error creating the repository: %v", err)
+	}
+	if repo.CloneURL != "https://gitea.example.com/genezio/deployments.git" || repo.DefaultBranch != "trunk" {
+		t.Fatalf("unexpected repository %+v", repo)
+	}
+	if created["name"] != "deployments" || created["private"] != true || created["default_branch"] != "trunk" {
+		t.Fatalf("unexpected repository creation request %v", created)
+	}
+	if commit["branch"] != "trunk" || len(commit["files"].([]interface{})) != len(giteaSkeletonFiles) {
+		t.Fatalf("unexpected initial commit %v", commit)
+	}
+
+	// A repository left empty by a failed initial commit gets its skeleton on the next pass
+	created, commit = map[string]interface{}{}, map[string]interface{}{}
+	existing = `{"clone_url":"https://gitea.example.com/genezio/deployments.git","default_branch":"trunk","empty":true}`
+	if repo, err = provider.EnsureRepository(ctx, gitConfig, gitAuth{token: "secret"}); err != nil ||
+		repo.DefaultBranch != "trunk" {
+		t.Fatalf("unexpected result for an empty repository %+v, %v", repo, err)
+	}
+	if len(created) != 0 || commit["branch"] != "trunk" {
+		t.Fatalf("expected only the skeleton to be committed, got %v and %v", created, commit)
+	}
+
+	commit = map[string]interface{}{}
+	existing = `{"clone_url":"https://gitea.example.com/genezio/deployments.git","default_branch":"trunk","empty":false}`
+	if _, err = provider.EnsureRepository(ctx, gitConfig, gitAuth{token: "secret"}); err != nil || len(commit) != 0 {
+		t.Fatalf("expected an initialized repository to be left alone, got %v, %v", commit, err)
+	}
+}
+
+func TestGiteaProviderTokens(t *testing.T) {
+	minted := map[string]interface{}{}
+	deleted := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "genezio" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/users/genezio/tokens":
+			_ = json.NewDecoder(r.Body).Decode(&minted)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":1,"name":"operator","sha1":"minted-token"}`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/api/v1/users/genezio/tokens/previous":
+			deleted = "previous"
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	provider := giteaProvider{}
+	gitConfig := initv1alpha1.GitConfig{
+		Provider: "gitea",
+		Gitea:    initv1alpha1.GiteaProvider{URL: server.URL, Username: "genezio", Password: "secret"},
+	}
+	auth := gitAuth{username: "genezio", password: "secret"}
+
+	token, err := provider.createToken(ctx, gitConfig, auth, "operator", defaultGiteaTokenScopes)
+	if err != nil {
+		t.Fatalf("unexpected error minting a token: %v", err)
+	}
+	if token != "minted-token" || minted["name"] != "operator" || len(minted["scopes"].([]interface{})) != 2 {
+		t.Fatalf("unexpected token %q minted with %v", token, minted)
+	}
+	if _, err := provider.createToken(ctx, gitConfig, gitAuth{username: "genezio", password: "wrong"}, "operator",
+		defaultGiteaTokenScopes); !errors.Is(err, errGitUnauthorized) {
+		t.Fatalf("expected errGitUnauthorized, got %v", err)
+	}
+
+	if err := provider.deleteToken(ctx, gitConfig, auth, "previous"); err != nil || deleted != "previous" {
+		t.Fatalf("unexpected error revoking a token: %v", err)
+	}
+	if err := provider.deleteToken(ctx, gitConfig, auth, "gone"); err != nil {
+		t.Fatalf("expected revoking a missing token to succeed, got %v", err)
+	}
+}
+
+func TestGitHubProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v3/user":
+			_, _ = w.Write([]byte(`{}`))
+		case "/api/v3/repos/genezio-org/deployments":
+			_, _ = w.Write([]byte(`{"clone_url":"https://github.example.com/genezio-org/deployments.git",` +
+				`"default_branch":"main"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	provider := gitProviders["github"]
+	if url := (githubProvider{}).hostURL(initv1alpha1.GitConfig{}); url != "https://github.com" {
+		t.Fatalf("expected github.com to be the default git host, got %s", url)
+	}
+
+	gitConfig := initv1alpha1.GitConfig{
+		Provider:            "github",
+		DeployementRepoName: "deployments",
+		GitHub:              initv1alpha1.GitHubProvider{APIURL: server.URL + "/api/v3", Owner: "genezio-org"},
+	}
+
+	var specErr *specError
+	if err := validateGitConfig(gitConfig); !errors.As(err, &specErr) {
+		t.Fatalf("expected a missing token to be rejected, got %v", err)
+	}
+	gitConfig.GitHub.Token = "secret"
+	if err := validateGitConfig(gitConfig); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	auth := gitAuth{token: "secret"}
+	if err := provider.CheckConnectivity(ctx, gitConfig, auth); err != nil {
+		t.Fatalf("unexpected error checking connectivity: %v", err)
+	}
+	repo, err := provider.EnsureRepository(ctx, gitConfig, auth)
+	if err != nil {
+		t.Fatalf("unexpected error ensuring the repository: %v", err)
+	}
+	if repo.CloneURL != "https://github.example.com/genezio-org/deployments.git" || repo.DefaultBranch != "main" {
+		t.Fatalf("unexpected repository %+v", repo)
+	}
+	gitConfig.DeployementRepoName = "missing"
+	if _, err := provider.EnsureRepository(ctx, gitConfig, auth); !errors.Is(err, errGitRepositoryNotFound) {
+		t.Fatalf("expected errGitRepositoryNotFound for a missing repository, got %v", err)
+	}
+	if _, err := provider.EnsureRepository(ctx, gitConfig, gitAuth{token: "wrong"}); !errors.Is(err, errGitUnauthorized) {
+		t.Fatalf("expected errGitUnauthorized for a wrong token, got %v", err)
+	}
+
+	// The operand clones from the git host and calls the API separately
+	env := map[string]string{}
+	for _, e := range provider.Render(gitConfig, provider.Credentials(gitConfig)).env {
+		env[e.Name] = e.Value
+	}
+	if env["GIT_URL"] != server.URL || env["GIT_API_URL"] != server.URL+"/api/v3" ||
+		env["GIT_OWNER"] != "genezio-org" || env["GIT_USER"] != "x-access-token" || env["GIT_TOKEN"] != "secret" {
+		t.Fatalf("unexpected environment %v", env)
+	}
+}
+
+func TestGitLabProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.EscapedPath() {
+		case "/api/v4/user":
+			_, _ = w.Write([]byte(`{"username":"genezio"}`))
+		case "/api/v4/projects/platform%2Fapps%2Fdeployments", "/api/v4/projects/genezio%2Fdeployments":
+			_, _ = w.Write([]byte(`{"http_url_to_repo":"https://gitlab.example.com/deployments.git","default_branch":"main"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	provider := gitProviders["gitlab"]
+	gitConfig := initv1alpha1.GitConfig{
+		Provider:            "gitlab",
+		DeployementRepoName: "deployments",
+		GitLab: initv1alpha1.GitLabProvider{
+			URL:       server.URL,
+			Namespace: "platform/apps",
+			TokenType: "group",
+			Token:     "secret",
+		},
+	}
+	auth := gitAuth{token: "secret"}
+
+	if err := validateGitConfig(gitConfig); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	repo, err := provider.EnsureRepository(ctx, gitConfig, auth)
+	if err != nil {
+		t.Fatalf("unexpected error ensuring the repository in a subgroup: %v", err)
+	}
+	if repo.CloneURL != "https://gitlab.example.com/deployments.git" || repo.DefaultBranch != "main" {
+		t.Fatalf("unexpected repository %+v", repo)
+	}
+
+	gitConfig.GitLab.Namespace = ""
+	if _, err := provider.EnsureRepository(ctx, gitConfig, auth); err != nil {
+		t.Fatalf("unexpected error ensuring the repository in the user namespace: %v", err)
+	}
+
+	gitConfig.GitLab.Namespace = "platform//apps"
+	var specErr *specError
+	if err := validateGitConfig(gitConfig); !errors.As(err, &specErr) {
+		t.Fatalf("expected an invalid namespace to be rejected, got %v", err)
+	}
+}
+
+func TestBitbucketProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/rest/api/1.0/projects/GEN":
+			_, _ = w.Write([]byte(`{}`))
+		case "/rest/api/1.0/projects/GEN/repos/deployments":
+			_, _ = w.Write([]byte(`{"links":{"clone":[{"href":"ssh://git@bitbucket.example.com/gen/deployments.git",` +
+				`"name":"ssh"},{"href":"https://bitbucket.example.com/scm/gen/deployments.git","name":"http"}]}}`))
+		case "/rest/api/1.0/projects/GEN/repos/deployments/default-branch":
+			_, _ = w.Write([]byte(`{"displayId":"main"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	provider := gitProviders["bitbucket"]
+	gitConfig := initv1alpha1.GitConfig{
+		Provider:            "bitbucket",
+		DeployementRepoName: "deployments",
+		Bitbucket: initv1alpha1.BitbucketProvider{
+			URL:        server.URL,
+			ProjectKey: "GEN",
+			Username:   "genezio",
+			Token:      "secret",
+		},
+	}
+
+	if err := validateGitConfig(gitConfig); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	if err := provider.CheckConnectivity(ctx, gitConfig, gitAuth{token: "wrong"}); !errors.Is(err, errGitUnauthorized) {
+		t.Fatalf("expected errGitUnauthorized, got %v", err)
+	}
+	repo, err := provider.EnsureRepository(ctx, gitConfig, gitAuth{token: "secret"})
+	if err != nil {
+		t.Fatalf("unexpected error ensuring the repository: %v", err)
+	}
+	if repo.CloneURL != "https://bitbucket.example.com/scm/gen/deployments.git" || repo.DefaultBranch != "main" {
+		t.Fatalf("unexpected repository %+v", repo)
+	}
+
+	gitConfig.Bitbucket.RepoSlug = "missing"
+	if _, err := provider.EnsureRepository(ctx, gitConfig, gitAuth{token: "secret"}); !errors.Is(err, errGitRepositoryNotFound) {
+		t.Fatalf("expected errGitRepositoryNotFound, got %v", err)
+	}
+
+	env := map[string]string{}
+	for _, e := range provider.Render(gitConfig, provider.Credentials(gitConfig)).env {
+		env[e.Name] = e.Value
+	}
+	if env["GIT_URL"] != server.URL || env["GIT_USER"] != "genezio" || env["GIT_TOKEN"] != "secret" {
+		t.Fatalf("unexpected environment %v", env)
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Default keys of the Secrets holding the SSH private key and known_hosts. The
+// private key default matches the kubernetes.io/ssh-auth Secret type.
+const (
+	defaultSSHPrivateKeySecretKey = corev1.SSHAuthPrivateKey
+	defaultSSHKnownHostsSecretKey = "known_hosts"
+)
+
+// gitSSHMountPath is where the SSH private key and known_hosts are mounted in the operand
+const gitSSHMountPath = "/etc/genezio/git-ssh"
+
+// reasonInvalidKnownHosts is used on the Degraded condition when known_hosts holds no usable key
+const reasonInvalidKnownHosts = "InvalidKnownHosts"
+
+// sshCredentialsFor returns the private key and known_hosts references of the SSH configuration
+func sshCredentialsFor(ssh *initv1alpha1.GitSSHConfig) (credential, credential) {
+	if ssh == nil {
+		return credential{}, credential{}
+	}
+	privateKey := credential{
+		path:       "spec.gitConfig.ssh.privateKey",
+		secretName: ssh.PrivateKeySecretName,
+		secretKey:  ssh.PrivateKeySecretKey,
+	}
+	if privateKey.secretKey == "" {
+		privateKey.secretKey = defaultSSHPrivateKeySecretKey
+	}
+	knownHosts := credential{
+		path:       "spec.gitConfig.ssh.knownHosts",
+		secretName: ssh.KnownHostsSecretName,
+		secretKey:  ssh.KnownHostsSecretKey,
+	}
+	if knownHosts.secretKey == "" {
+		knownHosts.secretKey = defaultSSHKnownHostsSecretKey
+	}
+	return privateKey, knownHosts
+}
+
+// validateGitSSHConfig checks the SSH configuration, if any
+func validateGitSSHConfig(ssh *initv1alpha1.GitSSHConfig) error {
+	return specErrorFor(reasonInvalidGitConfig,
+		initv1alpha1.ValidateGitSSHConfig(ssh, field.NewPath("spec", "gitConfig", "ssh")))
+}
+
+// renderGitSSHOperandConfig mounts the private key and known_hosts into the operand
+// and makes git use them. The private key is only readable by the owner and the
+// group of the pod, which ssh accepts since the file is owned by root.
+func renderGitSSHOperandConfig(ssh *initv1alpha1.GitSSHConfig, privateKey, knownHosts credential) gitOperandConfig {
+	if ssh == nil {
+		return gitOperandConfig{}
+	}
+
+	return gitOperandConfig{
+		env: []corev1.EnvVar{
+			{
+				Name:  "GIT_SSH_URL",
+				Value: ssh.CloneURL,
+			},
+			{
+				Name: "GIT_SSH_COMMAND",
+				Value: fmt.Sprintf("ssh -i %s/id -o IdentitiesOnly=yes -o UserKnownHostsFile=%s/known_hosts "+
+					"-o StrictHostKeyChecking=yes", gitSSHMountPath, gitSSHMountPath),
+			},
+		},
+		volumes: []corev1.Volume{{
+			Name: "git-ssh",
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{
+						{
+							Secret: &corev1.SecretProjection{
+								LocalObjectReference: corev1.LocalObjectReference{Name: privateKey.secretName},
+								Items: []corev1.KeyToPath{{
+									Key:  privateKey.secretKey,
+									Path: "id",
+									Mode: &[]int32{0440}[0],
+								}},
+							},
+						},
+						{
+							Secret: &corev1.SecretProjection{
+								LocalObjectReference: corev1.LocalObjectReference{Name: knownHosts.secretName},
+								Items: []corev1.KeyToPath{{
+									Key:  knownHosts.secretKey,
+									Path: "known_hosts",
+									Mode: &[]int32{0444}[0],
+								}},
+							},
+						},
+					},
+				},
+			},
+		}},
+		volumeMounts: []corev1.VolumeMount{{
+			Name:      "git-ssh",
+			MountPath: gitSSHMountPath,
+			ReadOnly:  true,
+		}},
+	}
+}
+
+// knownHostsKeys parses known_hosts and returns the SHA256 fingerprint of every
+// host key, in the format printed by ssh-keygen -l
+func knownHostsKeys(knownHosts string) ([]initv1alpha1.SSHHostKey, error) {
+	var keys []initv1alpha1.SSHHostKey
+	scanner := bufio.NewScanner(strings.NewReader(knownHosts))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		// Markers such as @cert-authority or @revoked prefix the hosts
+		if strings.HasPrefix(fields[0], "@") {
+			if fields[0] == "@revoked" {
+				continue
+			}
+			fields = fields[1:]
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d of known_hosts is not of the form \"hosts type key\"", line)
+		}
+
+		blob, err := base64.StdEncoding.DecodeString(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d of known_hosts holds an invalid key: %w", line, err)
+		}
+		sum := sha256.Sum256(blob)
+		keys = append(keys, initv1alpha1.SSHHostKey{
+			Hosts:       fields[0],
+			Type:        fields[1],
+			Fingerprint: "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("known_hosts holds no host key")
+	}
+	return keys, nil
+}
+
+// checkSSHHostKeys parses the known_hosts of the SSH configuration and publishes
+// the fingerprints of the trusted host keys in the status of the GenezioManager
+func (r *GenezioManagerReconciler) checkSSHHostKeys(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) error {
+	if geneziomanager.Spec.GitConfig.SSH == nil {
+		geneziomanager.Status.SSHHostKeys = nil
+		return nil
+	}
+
+	knownHosts, err := r.resolveCredential(ctx, geneziomanager.Namespace, credentialsFor(geneziomanager).sshKnownHosts)
+	if err != nil {
+		return err
+	}
+	keys, err := knownHostsKeys(knownHosts)
+	if err != nil {
+		return &specError{Reason: reasonInvalidKnownHosts, Message: err.Error()}
+	}
+	geneziomanager.Status.SSHHostKeys = keys
+	return nil
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+)
+
+func TestKnownHostsKeys(t *testing.T) {
+	knownHosts := `# trusted git hosts
+gitea.example.com,10.0.0.1 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAII87KHkAMT4BMwXyCPuJn2QmhWNukw+dzJDMcbXR3/Tb
+@revoked old.example.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAII87KHkAMT4BMwXyCPuJn2QmhWNukw+dzJDMcbXR3/Tb
+`
+
+	keys, err := knownHostsKeys(knownHosts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("expected a single trusted key, got %v", keys)
+	}
+	// Fingerprint as printed by ssh-keygen -l
+	if keys[0].Hosts != "gitea.example.com,10.0.0.1" || keys[0].Type != "ssh-ed25519" ||
+		keys[0].Fingerprint != "SHA256:jnl2bC8wl6vEYb9SwcfnJAOE5RQyeBecJHoXa/bXMhw" {
+		t.Fatalf("unexpected key %+v", keys[0])
+	}
+
+	if _, err := knownHostsKeys("# nothing trusted\n"); err == nil {
+		t.Fatalf("expected an error for known_hosts without keys")
+	}
+	if _, err := knownHostsKeys("gitea.example.com ssh-ed25519 not-base64!\n"); err == nil {
+		t.Fatalf("expected an error for an invalid key")
+	}
+}
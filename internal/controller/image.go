@@ -0,0 +1,385 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// imageEnvVar holds the default genezio-manager image, set in config/manager/manager.yaml
+const imageEnvVar = "GENEZIO_MANAGER_IMAGE"
+
+// Reasons used on the Degraded condition when the image cannot be pinned
+const (
+	reasonInvalidImage      = "InvalidImage"
+	reasonImageNotFound     = "ImageNotFound"
+	reasonImageUnauthorized = "ImageUnauthorized"
+)
+
+// errImageNotFound is returned when the registry does not know the tag of the image
+var errImageNotFound = errors.New("the image was not found in the registry")
+
+// manifestMediaTypes are the manifests accepted when resolving a tag, image indexes
+// first so that the digest of a multi-arch image is the one of the index
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+var (
+	imageDomainRegexp    = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*(:[0-9]+)?$`)
+	imageComponentRegexp = regexp.MustCompile(`^[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*$`)
+	imageTagRegexp       = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127}$`)
+	imageDigestRegexp    = regexp.MustCompile(`^[a-z0-9]+([+._-][a-z0-9]+)*:[a-zA-Z0-9=_-]{32,}$`)
+)
+
+// imageReference is an image reference split like repository[:tag][@digest]
+type imageReference struct {
+	// Repository includes the registry host when there is one, e.g. ghcr.io/genez-io/genezio-manager
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// parseImageReference splits and validates an image reference of any form accepted
+// by the kubelet, e.g. nginx, registry:5000/genezio/manager:v1 or ghcr.io/genez-io/manager@sha256:...
+func parseImageReference(image string) (imageReference, error) {
+	ref := imageReference{Repository: image}
+	name, digest, hasDigest := strings.Cut(ref.Repository, "@")
+	if hasDigest {
+		ref.Repository, ref.Digest = name, digest
+	}
+	// A colon after the last slash separates the tag, before it the port of the registry
+	i := strings.LastIndex(ref.Repository, ":")
+	hasTag := i > strings.LastIndex(ref.Repository, "/")
+	if hasTag {
+		ref.Repository, ref.Tag = ref.Repository[:i], ref.Repository[i+1:]
+	}
+	err := ref.validate()
+	if err == nil && ((hasTag && ref.Tag == "") || (hasDigest && ref.Digest == "")) {
+		err = errors.New("the tag or the digest is empty")
+	}
+	if err != nil {
+		return imageReference{}, fmt.Errorf("invalid image reference %q: %w", image, err)
+	}
+	return ref, nil
+}
+
+// validate checks the parts of the reference against the grammar of the distribution spec
+func (ref imageReference) validate() error {
+	if ref.Repository == "" {
+		return errors.New("the repository is empty")
+	}
+	components := strings.Split(ref.Repository, "/")
+	if ref.hasRegistry() {
+		if !imageDomainRegexp.MatchString(components[0]) {
+			return fmt.Errorf("invalid registry %q", components[0])
+		}
+		components = components[1:]
+	}
+	for _, component := range components {
+		if !imageComponentRegexp.MatchString(component) {
+			return fmt.Errorf("invalid repository path component %q", component)
+		}
+	}
+	if ref.Tag != "" && !imageTagRegexp.MatchString(ref.Tag) {
+		return fmt.Errorf("invalid tag %q", ref.Tag)
+	}
+	if ref.Digest != "" && !imageDigestRegexp.MatchString(ref.Digest) {
+		return fmt.Errorf("invalid digest %q", ref.Digest)
+	}
+	return nil
+}
+
+// hasRegistry tells whether the first component of the repository is a registry
+// host, which like in docker is recognized by a dot, a port or localhost
+func (ref imageReference) hasRegistry() bool {
+	first, _, found := strings.Cut(ref.Repository, "/")
+	return found && (strings.ContainsAny(first, ".:") || first == "localhost")
+}
+
+// registry returns the host of the registry serving the image
+func (ref imageReference) registry() string {
+	if ref.hasRegistry() {
+		first, _, _ := strings.Cut(ref.Repository, "/")
+		return first
+	}
+	return "docker.io"
+}
+
+// path returns the name of the repository on its registry
+func (ref imageReference) path() string {
+	if ref.hasRegistry() {
+		_, path, _ := strings.Cut(ref.Repository, "/")
+		return path
+	}
+	if !strings.Contains(ref.Repository, "/") {
+		return "library/" + ref.Repository
+	}
+	return ref.Repository
+}
+
+// registryURL returns the base URL of the registry API serving the image
+func (ref imageReference) registryURL() string {
+	return registryBaseURL(ref.registry())
+}
+
+func (ref imageReference) String() string {
+	image := ref.Repository
+	if ref.Tag != "" {
+		image += ":" + ref.Tag
+	}
+	if ref.Digest != "" {
+		image += "@" + ref.Digest
+	}
+	return image
+}
+
+// versionLabel returns the value of the app.kubernetes.io/version label for the image:
+// its tag, or the short form of its digest, trimmed to a valid label value
+func (ref imageReference) versionLabel() string {
+	version := ref.Tag
+	if version == "" && ref.Digest != "" {
+		_, encoded, _ := strings.Cut(ref.Digest, ":")
+		version = encoded
+		if len(version) > 12 {
+			version = version[:12]
+		}
+	}
+	if len(version) > 63 {
+		version = version[:63]
+	}
+	return strings.TrimRight(version, "-_.")
+}
+
+// desiredImage returns the image set on the operator with the overrides of the spec
+// applied. A digest of the default image is dropped when the spec changes the tag.
+func desiredImage(geneziomanager *initv1alpha1.GenezioManager) (imageReference, error) {
+	ref := imageReference{}
+	if image, found := os.LookupEnv(imageEnvVar); found {
+		var err error
+		if ref, err = parseImageReference(image); err != nil {
+			return imageReference{}, fmt.Errorf("invalid %s environment variable: %w", imageEnvVar, err)
+		}
+	}
+
+	override := geneziomanager.Spec.Image
+	if override != nil {
+		if override.Repository != "" {
+			ref.Repository = override.Repository
+		}
+		if override.Tag != "" {
+			ref.Tag = override.Tag
+			ref.Digest = ""
+		}
+		if override.Digest != "" {
+			ref.Digest = override.Digest
+		}
+	}
+	if ref.Repository == "" {
+		return imageReference{}, fmt.Errorf("unable to find %s environment variable with the image", imageEnvVar)
+	}
+	if override != nil {
+		if err := ref.validate(); err != nil {
+			return imageReference{}, &specError{Reason: reasonInvalidImage,
+				Message: fmt.Sprintf("spec.image results in an invalid image %q: %s", ref, err)}
+		}
+	}
+	return ref, nil
+}
+
+// imageForGenezioManager returns the operand image managed by this controller, pinned
+// to the digest resolved from its tag when the spec asks for it. Until the digest of a
+// new tag has been resolved, the operand keeps running the image pinned last.
+func imageForGenezioManager(geneziomanager *initv1alpha1.GenezioManager) (imageReference, error) {
+	ref, err := desiredImage(geneziomanager)
+	if err != nil {
+		return imageReference{}, err
+	}
+	if ref.Digest != "" || geneziomanager.Spec.Image == nil || !geneziomanager.Spec.Image.ResolveDigest ||
+		geneziomanager.Status.ImageDigest == "" {
+		return ref, nil
+	}
+
+	// status.image records the tag status.imageDigest was resolved from
+	previous, err := parseImageReference(geneziomanager.Status.Image)
+	if err != nil || previous.Digest != geneziomanager.Status.ImageDigest {
+		return ref, nil
+	}
+	if previous.Repository == ref.Repository && previous.Tag == ref.Tag {
+		ref.Digest = previous.Digest
+		return ref, nil
+	}
+	return previous, nil
+}
+
+// imagePullPolicyForGenezioManager returns the pull policy of the operand container
+func imagePullPolicyForGenezioManager(spec initv1alpha1.GenezioManagerSpec) corev1.PullPolicy {
+	if spec.Image != nil && spec.Image.PullPolicy != "" {
+		return spec.Image.PullPolicy
+	}
+	return corev1.PullAlways
+}
+
+// resolveImageDigest records in status.imageDigest the digest the operand image is
+// pinned to. A tag is resolved against the registry only when the image changes, so
+// that the Deployment does not follow a tag moved in the registry. When the registry
+// cannot be reached, the last digest is kept and an error is returned to retry.
+func (r *GenezioManagerReconciler) resolveImageDigest(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) error {
+	ref, err := desiredImage(geneziomanager)
+	var specErr *specError
+	if errors.As(err, &specErr) {
+		return err
+	} else if err != nil {
+		// A missing default image is reported when rendering the Deployment
+		return nil
+	}
+
+	image := geneziomanager.Spec.Image
+	if image == nil || !image.ResolveDigest || ref.Digest != "" {
+		geneziomanager.Status.ImageDigest = ref.Digest
+		return nil
+	}
+	pinned := ref
+	pinned.Digest = geneziomanager.Status.ImageDigest
+	if pinned.Digest != "" && geneziomanager.Status.Image == pinned.String() {
+		return nil
+	}
+
+	// The credentials of the container registry are used when it also serves the image
+	var username, password string
+	registryConfig := geneziomanager.Spec.ContainerRegistryConfig
+	if registryConfig.URL != "" && registryHost(registryConfig.URL) == ref.registry() {
+		username = registryConfig.Username
+		password, err = r.resolveCredential(ctx, geneziomanager.Namespace, credentialsFor(geneziomanager).registryPassword)
+		if err != nil {
+			return err
+		}
+	}
+
+	digest, err := registryResolveDigest(ctx, ref.registryURL(), ref.path(), ref.Tag, username, password)
+	switch {
+	case errors.Is(err, errImageNotFound):
+		return &specError{Reason: reasonImageNotFound,
+			Message: fmt.Sprintf("The image %s was not found in its registry", ref)}
+	case errors.Is(err, errRegistryUnauthorized):
+		return &specError{Reason: reasonImageUnauthorized,
+			Message: fmt.Sprintf("The registry %s refused access to the image %s", ref.registry(), ref)}
+	case err != nil:
+		// status.imageDigest is kept, so the operand stays on the image pinned last
+		return fmt.Errorf("failed to resolve the digest of the image %s: %w", ref, err)
+	}
+	pinned.Digest = digest
+	geneziomanager.Status.ImageDigest = digest
+	geneziomanager.Status.Image = pinned.String()
+	return nil
+}
+
+// registryResolveDigest returns the digest of the manifest a tag points to. Like
+// registryLogin, it meets the challenge of the registry with basic auth or a bearer
+// token, anonymous when no username is given, scoped to pulling the repository.
+func registryResolveDigest(ctx context.Context, registryURL, path, tag, username, password string) (string, error) {
+	if tag == "" {
+		tag = "latest"
+	}
+	endpoint := fmt.Sprintf("%s/v2/%s/manifests/%s", strings.TrimSuffix(registryURL, "/"), path, tag)
+	accept := func(req *http.Request) { req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", ")) }
+
+	resp, err := registryDo(ctx, http.MethodHead, endpoint, accept, nil)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		basicAuth := func(req *http.Request) { req.SetBasicAuth(username, password) }
+		scheme, params := parseAuthChallenge(resp.Header.Get("WWW-Authenticate"))
+		switch {
+		case scheme == "basic" && username != "":
+			resp, err = registryDo(ctx, http.MethodHead, endpoint, func(req *http.Request) {
+				accept(req)
+				basicAuth(req)
+			}, nil)
+		case scheme == "bearer" && params["realm"] != "":
+			realm, parseErr := url.Parse(params["realm"])
+			if parseErr != nil {
+				return "", fmt.Errorf("invalid token realm %q: %w", params["realm"], parseErr)
+			}
+			query := realm.Query()
+			if params["service"] != "" {
+				query.Set("service", params["service"])
+			}
+			query.Set("scope", fmt.Sprintf("repository:%s:pull", path))
+			if username != "" {
+				query.Set("account", username)
+			}
+			realm.RawQuery = query.Encode()
+
+			var authenticate func(*http.Request)
+			if username != "" {
+				authenticate = basicAuth
+			}
+			token := struct {
+				Token       string `json:"token"`
+				AccessToken string `json:"access_token"`
+			}{}
+			resp, err = registryDo(ctx, http.MethodGet, realm.String(), authenticate, &token)
+			if err != nil {
+				return "", err
+			}
+			if resp.StatusCode != http.StatusOK {
+				break
+			}
+			bearer := token.Token
+			if bearer == "" {
+				bearer = token.AccessToken
+			}
+			resp, err = registryDo(ctx, http.MethodHead, endpoint, func(req *http.Request) {
+				accept(req)
+				req.Header.Set("Authorization", "Bearer "+bearer)
+			}, nil)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return "", errRegistryUnauthorized
+	case resp.StatusCode == http.StatusNotFound:
+		return "", errImageNotFound
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("%s %s returned %s", resp.Request.Method, resp.Request.URL, resp.Status)
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if !imageDigestRegexp.MatchString(digest) {
+		return "", fmt.Errorf("%s returned no valid Docker-Content-Digest header", endpoint)
+	}
+	return digest, nil
+}
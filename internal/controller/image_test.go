@@ -0,0 +1,207 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestParseImageReference(t *testing.T) {
+	tests := []struct {
+		image    string
+		want     imageReference
+		registry string
+		path     string
+		version  string
+		invalid  bool
+	}{
+		{image: "nginx", want: imageReference{Repository: "nginx"},
+			registry: "docker.io", path: "library/nginx"},
+		{image: "genezio/manager:v1.2.0", want: imageReference{Repository: "genezio/manager", Tag: "v1.2.0"},
+			registry: "docker.io", path: "genezio/manager", version: "v1.2.0"},
+		{image: "registry:5000/genezio/manager", want: imageReference{Repository: "registry:5000/genezio/manager"},
+			registry: "registry:5000", path: "genezio/manager"},
+		{image: "localhost/manager:dev", want: imageReference{Repository: "localhost/manager", Tag: "dev"},
+			registry: "localhost", path: "manager", version: "dev"},
+		{image: "ghcr.io/genez-io/manager:v1@" + testDigest,
+			want:     imageReference{Repository: "ghcr.io/genez-io/manager", Tag: "v1", Digest: testDigest},
+			registry: "ghcr.io", path: "genez-io/manager", version: "v1"},
+		{image: "ghcr.io/genez-io/manager@" + testDigest,
+			want:     imageReference{Repository: "ghcr.io/genez-io/manager", Digest: testDigest},
+			registry: "ghcr.io", path: "genez-io/manager", version: "0123456789ab"},
+		{image: "", invalid: true},
+		{image: "Genezio/Manager", invalid: true},
+		{image: "ghcr.io/genez-io/manager:", invalid: true},
+		{image: "ghcr.io/genez-io/manager:v1@sha256:short", invalid: true},
+		{image: "-registry.io/manager", invalid: true},
+	}
+	for _, tt := range tests {
+		ref, err := parseImageReference(tt.image)
+		if tt.invalid {
+			if err == nil {
+				t.Errorf("expected an error parsing %q, got %+v", tt.image, ref)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error parsing %q: %v", tt.image, err)
+			continue
+		}
+		if ref != tt.want {
+			t.Errorf("parsing %q: expected %+v, got %+v", tt.image, tt.want, ref)
+		}
+		if ref.String() != tt.image {
+			t.Errorf("expected %q to be rendered back, got %q", tt.image, ref)
+		}
+		if ref.registry() != tt.registry || ref.path() != tt.path || ref.versionLabel() != tt.version {
+			t.Errorf("%q: expected registry %q, path %q and version %q, got %q, %q and %q", tt.image,
+				tt.registry, tt.path, tt.version, ref.registry(), ref.path(), ref.versionLabel())
+		}
+	}
+}
+
+func TestVersionLabelTruncated(t *testing.T) {
+	ref := imageReference{Repository: "genezio/manager", Tag: strings.Repeat("a", 62) + ".b"}
+	if version := ref.versionLabel(); version != strings.Repeat("a", 62) {
+		t.Errorf("expected the tag to be cut to a valid label value, got %q", version)
+	}
+}
+
+func TestDesiredImage(t *testing.T) {
+	t.Setenv(imageEnvVar, "example.com/genezio-manager:test@"+testDigest)
+	geneziomanager := &initv1alpha1.GenezioManager{}
+
+	ref, err := desiredImage(geneziomanager)
+	if err != nil || ref.String() != "example.com/genezio-manager:test@"+testDigest {
+		t.Errorf("expected the image of the operator, got %q, %v", ref, err)
+	}
+
+	geneziomanager.Spec.Image = &initv1alpha1.ImageConfig{Repository: "mirror.example.com/genezio-manager"}
+	if ref, err = desiredImage(geneziomanager); err != nil ||
+		ref.String() != "mirror.example.com/genezio-manager:test@"+testDigest {
+		t.Errorf("expected the repository to be overridden, got %q, %v", ref, err)
+	}
+
+	// The digest of the operator image does not belong to another tag
+	geneziomanager.Spec.Image.Tag = "v2"
+	if ref, err = desiredImage(geneziomanager); err != nil || ref.String() != "mirror.example.com/genezio-manager:v2" {
+		t.Errorf("expected the tag to be overridden without the digest, got %q, %v", ref, err)
+	}
+
+	geneziomanager.Spec.Image.ResolveDigest = true
+	geneziomanager.Status.ImageDigest = testDigest
+	geneziomanager.Status.Image = "mirror.example.com/genezio-manager:v2@" + testDigest
+	if ref, err = imageForGenezioManager(geneziomanager); err != nil ||
+		ref.String() != "mirror.example.com/genezio-manager:v2@"+testDigest {
+		t.Errorf("expected the image to be pinned to the resolved digest, got %q, %v", ref, err)
+	}
+
+	// The digest of the previous tag is kept until the new tag is resolved
+	geneziomanager.Spec.Image.Tag = "v3"
+	if ref, err = imageForGenezioManager(geneziomanager); err != nil ||
+		ref.String() != "mirror.example.com/genezio-manager:v2@"+testDigest {
+		t.Errorf("expected the previous image to be kept, got %q, %v", ref, err)
+	}
+	geneziomanager.Status.ImageDigest = ""
+	if ref, err = imageForGenezioManager(geneziomanager); err != nil || ref.String() != "mirror.example.com/genezio-manager:v3" {
+		t.Errorf("expected the tag without a resolved digest, got %q, %v", ref, err)
+	}
+
+	geneziomanager.Spec.Image.Repository = "mirror.example.com/Genezio"
+	var specErr *specError
+	if _, err = desiredImage(geneziomanager); !errors.As(err, &specErr) || specErr.Reason != reasonInvalidImage {
+		t.Errorf("expected an %s error for an invalid repository, got %v", reasonInvalidImage, err)
+	}
+
+	t.Setenv(imageEnvVar, "example.com/genezio-manager:")
+	geneziomanager.Spec.Image = nil
+	if _, err = desiredImage(geneziomanager); err == nil || errors.As(err, &specErr) {
+		t.Errorf("expected an error for an invalid operator image, got %v", err)
+	}
+}
+
+func TestRegistryResolveDigest(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer pull-token" {
+			w.Header().Set("WWW-Authenticate",
+				fmt.Sprintf(`Bearer realm="%s/token",service="registry.example.com"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodHead || !strings.Contains(r.Header.Get("Accept"), manifestMediaTypes[0]) ||
+			r.URL.Path != "/v2/genezio/manager/manifests/v1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", testDigest)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("scope") != "repository:genezio/manager:pull" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"pull-token"}`))
+	})
+
+	ctx := context.Background()
+	if digest, err := registryResolveDigest(ctx, server.URL, "genezio/manager", "v1", "", ""); err != nil ||
+		digest != testDigest {
+		t.Errorf("expected the digest of the tag, got %q, %v", digest, err)
+	}
+	if _, err := registryResolveDigest(ctx, server.URL, "genezio/manager", "v2", "", ""); !errors.Is(err, errImageNotFound) {
+		t.Errorf("expected errImageNotFound for an unknown tag, got %v", err)
+	}
+	if _, err := registryResolveDigest(ctx, server.URL, "genezio/other", "v1", "", ""); !errors.Is(err, errRegistryUnauthorized) {
+		t.Errorf("expected errRegistryUnauthorized for a repository out of the scope, got %v", err)
+	}
+}
+
+func TestResolveImageDigestUnreachableRegistry(t *testing.T) {
+	t.Setenv(imageEnvVar, "example.com/genezio-manager:test")
+	previous := "127.0.0.1:1/genezio/manager:v1@" + testDigest
+	geneziomanager := &initv1alpha1.GenezioManager{
+		Spec: initv1alpha1.GenezioManagerSpec{Image: &initv1alpha1.ImageConfig{
+			Repository: "127.0.0.1:1/genezio/manager", Tag: "v2", ResolveDigest: true}},
+		Status: initv1alpha1.GenezioManagerStatus{Image: previous, ImageDigest: testDigest},
+	}
+
+	r := &GenezioManagerReconciler{}
+	err := r.resolveImageDigest(context.Background(), geneziomanager)
+	var specErr *specError
+	if err == nil || errors.As(err, &specErr) {
+		t.Fatalf("expected a transient error for an unreachable registry, got %v", err)
+	}
+	if geneziomanager.Status.ImageDigest != testDigest {
+		t.Errorf("expected the last digest to be kept, got %q", geneziomanager.Status.ImageDigest)
+	}
+	if ref, err := imageForGenezioManager(geneziomanager); err != nil || ref.String() != previous {
+		t.Errorf("expected the operand to stay on %q, got %q, %v", previous, ref, err)
+	}
+}
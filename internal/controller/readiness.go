@@ -0,0 +1,186 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Reasons used on the GitReady and ArgoCDReady conditions
+const (
+	reasonGitRepositoryFound    = "RepositoryFound"
+	reasonGitUnreachable        = "GitUnreachable"
+	reasonArgoCDAuthenticated   = "Authenticated"
+	reasonArgoCDUnreachable     = "ArgoCDUnreachable"
+	reasonArgoCDProjectNotFound = "ArgoCDProjectNotFound"
+	reasonArgoCDNoCredentials   = "ArgoCDNoCredentials"
+)
+
+// dependencyCheckInterval is how often the git provider, ArgoCD and the container
+// registry are checked again, as credentials can be revoked without the spec changing
+const dependencyCheckInterval = 5 * time.Minute
+
+// dependencyConditionTypes are the conditions reporting the external systems the
+// genezio-manager depends on. Available is only true when none of them is false.
+var dependencyConditionTypes = []string{
+	typeGitReadyGenezioManager,
+	typeArgoCDReadyGenezioManager,
+	typeRegistryReadyGenezioManager,
+}
+
+// notReadyDependency returns the first dependency condition which is false, if any
+func notReadyDependency(conditions []metav1.Condition) *metav1.Condition {
+	for _, conditionType := range dependencyConditionTypes {
+		if condition := meta.FindStatusCondition(conditions, conditionType); condition != nil &&
+			condition.Status == metav1.ConditionFalse {
+			return condition
+		}
+	}
+	return nil
+}
+
+// dependencyCheckDue reports whether the external systems the genezio-manager depends
+// on have to be checked. They are checked again when the spec or one of the Secrets
+// of the pods changed, and otherwise once per dependencyCheckInterval, so that the
+// events of the pods do not turn into calls to the external systems.
+func dependencyCheckDue(geneziomanager *initv1alpha1.GenezioManager, secretHash string, now time.Time) bool {
+	status := geneziomanager.Status
+	return status.DependenciesCheckTime == nil || status.ObservedGeneration != geneziomanager.Generation ||
+		status.SecretHash != secretHash || dependencyCheckDelay(geneziomanager, now) <= 0
+}
+
+// dependencyCheckDelay returns how long until the external systems the genezio-manager
+// depends on have to be checked again
+func dependencyCheckDelay(geneziomanager *initv1alpha1.GenezioManager, now time.Time) time.Duration {
+	checkedAt := geneziomanager.Status.DependenciesCheckTime
+	if checkedAt == nil {
+		return 0
+	}
+	return checkedAt.Add(dependencyCheckInterval).Sub(now)
+}
+
+// setGitReadyCondition reports the outcome of the lookup of the deployment repository
+// on the GitReady condition
+func setGitReadyCondition(geneziomanager *initv1alpha1.GenezioManager, err error) {
+	gitConfig := geneziomanager.Spec.GitConfig
+	condition := metav1.Condition{Type: typeGitReadyGenezioManager, Status: metav1.ConditionTrue,
+		Reason: reasonGitRepositoryFound,
+		Message: fmt.Sprintf("The deployment repository %s is reachable on the %s provider",
+			gitConfig.DeployementRepoName, gitConfig.Provider)}
+	var specErr *specError
+	if errors.As(err, &specErr) {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = specErr.Reason
+		condition.Message = specErr.Message
+	} else if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonGitUnreachable
+		condition.Message = err.Error()
+	}
+	meta.SetStatusCondition(&geneziomanager.Status.Conditions, condition)
+}
+
+// argoCDProbeProject returns the AppProject the genezio-manager deploys to, if the
+// spec names one, which has to exist for ArgoCD to be ready
+func argoCDProbeProject(geneziomanager *initv1alpha1.GenezioManager) string {
+	argoCDConfig := geneziomanager.Spec.ArgoCDConfig
+	switch {
+	case argoCDConfig.AppProject != nil:
+		return argoCDProjectName(geneziomanager)
+	case argoCDConfig.TokenType == "project" && argoCDConfig.Project != "":
+		return argoCDConfig.Project
+	}
+	return ""
+}
+
+// checkArgoCD calls the API of ArgoCD with the token of the genezio-manager and checks
+// that its AppProject exists, reporting the outcome on the ArgoCDReady condition
+func (r *GenezioManagerReconciler) checkArgoCD(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) error {
+	log := log.FromContext(ctx)
+	argoCDConfig := geneziomanager.Spec.ArgoCDConfig
+	if cdEngine(geneziomanager.Spec) != cdEngineArgoCD || argoCDConfig.URL == "" {
+		meta.RemoveStatusCondition(&geneziomanager.Status.Conditions, typeArgoCDReadyGenezioManager)
+		return nil
+	}
+
+	// The genezio-manager authenticates with the generated token or with the password used as a token
+	cred := credentialsFor(geneziomanager).argoCDPassword
+	if usesArgoCDLogin(argoCDConfig) {
+		cred = argoCDTokenCredential(geneziomanager.Name)
+	}
+	token, err := r.resolveCredential(ctx, geneziomanager.Namespace, cred)
+	if err != nil {
+		return err
+	}
+
+	condition := metav1.Condition{Type: typeArgoCDReadyGenezioManager, Status: metav1.ConditionFalse,
+		Reason: reasonArgoCDNoCredentials, Message: "No ArgoCD token or password is configured for the genezio-manager"}
+	if token != "" {
+		userInfo := struct {
+			LoggedIn bool `json:"loggedIn"`
+		}{}
+		err := argoCDAPIDo(ctx, http.MethodGet, apiURL(argoCDConfig.URL, "/api/v1/session/userinfo"),
+			token, nil, &userInfo)
+		switch {
+		case errors.Is(err, errArgoCDUnauthorized) || (err == nil && !userInfo.LoggedIn):
+			condition.Reason = reasonArgoCDUnauthorized
+			condition.Message = fmt.Sprintf("ArgoCD at %s rejected the token of the genezio-manager", argoCDConfig.URL)
+		case err != nil:
+			log.Info("Failed to reach ArgoCD", "url", argoCDConfig.URL, "error", err.Error())
+			condition.Reason = reasonArgoCDUnreachable
+			condition.Message = fmt.Sprintf("Failed to reach ArgoCD at %s: %s", argoCDConfig.URL, err)
+		default:
+			condition.Status = metav1.ConditionTrue
+			condition.Reason = reasonArgoCDAuthenticated
+			condition.Message = fmt.Sprintf("Logged into ArgoCD at %s", argoCDConfig.URL)
+		}
+	}
+
+	// The AppProject is read from the cluster, project tokens are not allowed to read it
+	if project := argoCDProbeProject(geneziomanager); condition.Status == metav1.ConditionTrue && project != "" {
+		appProject := &unstructured.Unstructured{}
+		appProject.SetGroupVersionKind(appProjectGVK)
+		err := r.Get(ctx, types.NamespacedName{Name: project, Namespace: argoCDNamespace(argoCDConfig)}, appProject)
+		switch {
+		case meta.IsNoMatchError(err) || apierrors.IsNotFound(err):
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = reasonArgoCDProjectNotFound
+			condition.Message = fmt.Sprintf("The AppProject %s/%s does not exist",
+				argoCDNamespace(argoCDConfig), project)
+		case err != nil:
+			return err
+		default:
+			condition.Message = fmt.Sprintf("Logged into ArgoCD at %s, applications are deployed to the AppProject %s",
+				argoCDConfig.URL, project)
+		}
+	}
+	meta.SetStatusCondition(&geneziomanager.Status.Conditions, condition)
+	return nil
+}
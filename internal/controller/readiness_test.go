@@ -0,0 +1,98 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetGitReadyCondition(t *testing.T) {
+	geneziomanager := &initv1alpha1.GenezioManager{}
+	for _, tc := range []struct {
+		err    error
+		status metav1.ConditionStatus
+		reason string
+	}{
+		{nil, metav1.ConditionTrue, reasonGitRepositoryFound},
+		{&specError{Reason: reasonGitUnauthorized, Message: "rejected"}, metav1.ConditionFalse, reasonGitUnauthorized},
+		{errors.New("connection refused"), metav1.ConditionFalse, reasonGitUnreachable},
+	} {
+		setGitReadyCondition(geneziomanager, tc.err)
+		condition := meta.FindStatusCondition(geneziomanager.Status.Conditions, typeGitReadyGenezioManager)
+		if condition == nil || condition.Status != tc.status || condition.Reason != tc.reason {
+			t.Errorf("error %v: expected GitReady %s with reason %s, got %v", tc.err, tc.status, tc.reason, condition)
+		}
+	}
+}
+
+func TestNotReadyDependency(t *testing.T) {
+	conditions := []metav1.Condition{
+		{Type: typeGitReadyGenezioManager, Status: metav1.ConditionTrue, Reason: reasonGitRepositoryFound},
+		{Type: typeDegradedGenezioManager, Status: metav1.ConditionFalse, Reason: "Reconciling"},
+	}
+	if dependency := notReadyDependency(conditions); dependency != nil {
+		t.Fatalf("expected every dependency to be ready, got %v", dependency)
+	}
+
+	conditions = append(conditions, metav1.Condition{Type: typeRegistryReadyGenezioManager,
+		Status: metav1.ConditionFalse, Reason: reasonRegistryUnauthorized})
+	dependency := notReadyDependency(conditions)
+	if dependency == nil || dependency.Type != typeRegistryReadyGenezioManager {
+		t.Fatalf("expected RegistryReady to be reported, got %v", dependency)
+	}
+}
+
+func TestDependencyCheckDue(t *testing.T) {
+	now := time.Now()
+	checkedAt := metav1.NewTime(now.Add(-time.Minute))
+	geneziomanager := &initv1alpha1.GenezioManager{
+		ObjectMeta: metav1.ObjectMeta{Generation: 2},
+		Status: initv1alpha1.GenezioManagerStatus{
+			ObservedGeneration:    2,
+			SecretHash:            "hash",
+			DependenciesCheckTime: &checkedAt,
+		},
+	}
+	if dependencyCheckDue(geneziomanager, "hash", now) {
+		t.Errorf("expected no check a minute after the last one")
+	}
+	if delay := dependencyCheckDelay(geneziomanager, now); delay != dependencyCheckInterval-time.Minute {
+		t.Errorf("expected the next check in %s, got %s", dependencyCheckInterval-time.Minute, delay)
+	}
+	if !dependencyCheckDue(geneziomanager, "rotated", now) {
+		t.Errorf("expected a check once a Secret of the pods changed")
+	}
+	if !dependencyCheckDue(geneziomanager, "hash", now.Add(dependencyCheckInterval)) {
+		t.Errorf("expected a check once the interval passed")
+	}
+
+	geneziomanager.Generation = 3
+	if !dependencyCheckDue(geneziomanager, "hash", now) {
+		t.Errorf("expected a check once the spec changed")
+	}
+	geneziomanager.Generation = 2
+	geneziomanager.Status.DependenciesCheckTime = nil
+	if !dependencyCheckDue(geneziomanager, "hash", now) {
+		t.Errorf("expected a check before the first one")
+	}
+}
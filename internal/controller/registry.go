@@ -0,0 +1,290 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// componentRegistryPullSecret is the value of componentLabel for the copies of the
+// image pull Secret living in the namespaces of the applications
+const componentRegistryPullSecret = "registry-pull-secret"
+
+// reasonInvalidRegistryConfig is used on the Degraded condition when the registry
+// configuration cannot be reconciled
+const reasonInvalidRegistryConfig = "InvalidRegistryConfig"
+
+// validateRegistryConfig ensures that the registry URL is valid and that the namespaces
+// the image pull Secret is replicated to can be selected
+func validateRegistryConfig(registryConfig initv1alpha1.ContainerRegistryConfig) error {
+	return specErrorFor(reasonInvalidRegistryConfig,
+		initv1alpha1.ValidateRegistryConfig(registryConfig, field.NewPath("spec", "containerRegistryConfig")))
+}
+
+// usesRegistryPullSecret reports whether an image pull Secret is generated from the
+// registry credentials, which is the case as soon as the registry is configured
+func usesRegistryPullSecret(registryConfig initv1alpha1.ContainerRegistryConfig) bool {
+	return registryConfig.URL != "" && registryConfig.Username != ""
+}
+
+// registryPullSecretName returns the name of the image pull Secret of a GenezioManager.
+// The copies in other namespaces have the same name, so the applications can
+// reference it without knowing where it comes from.
+func registryPullSecretName(name string) string {
+	return name + "-registry"
+}
+
+// dockerHubConfigKey is the key of Docker Hub in a docker config. The kubelet and
+// the docker CLI look up the credentials of Docker Hub images under this legacy
+// index URL rather than under one of the hosts of the registry.
+const dockerHubConfigKey = "https://index.docker.io/v1/"
+
+// registryHost returns the registry URL without its scheme. The hosts of Docker Hub
+// are normalized to docker.io, the registry of images which do not name one.
+func registryHost(url string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
+	host = strings.TrimSuffix(host, "/")
+	name, _, _ := strings.Cut(host, "/")
+	switch name {
+	case "docker.io", "index.docker.io", "registry-1.docker.io":
+		return "docker.io"
+	}
+	return host
+}
+
+// dockerConfigKey returns the key of the registry in a docker config
+func dockerConfigKey(url string) string {
+	if host := registryHost(url); host != "docker.io" {
+		return host
+	}
+	return dockerHubConfigKey
+}
+
+// dockerConfigJSON renders the registry credentials as the content of a
+// kubernetes.io/dockerconfigjson Secret
+func dockerConfigJSON(url, username, password string) ([]byte, error) {
+	type authEntry struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	}
+	return json.Marshal(struct {
+		Auths map[string]authEntry `json:"auths"`
+	}{Auths: map[string]authEntry{
+		dockerConfigKey(url): {
+			Username: username,
+			Password: password,
+			Auth:     base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+		},
+	}})
+}
+
+// registryPullSecretFor returns the image pull Secret of the GenezioManager living in
+// the given namespace
+func registryPullSecretFor(geneziomanager *initv1alpha1.GenezioManager, namespace string,
+	dockerConfig []byte) *corev1.Secret {
+	ls := selectorLabelsForGenezioManager(geneziomanager.Name)
+	if namespace != geneziomanager.Namespace {
+		ls = crossNamespaceLabelsForGenezioManager(geneziomanager, componentRegistryPullSecret)
+	}
+	return &corev1.Secret{
+		// The type information is required by server-side apply
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      registryPullSecretName(geneziomanager.Name),
+			Namespace: namespace,
+			Labels:    ls,
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{corev1.DockerConfigJsonKey: dockerConfig},
+	}
+}
+
+// pullSecretReplicaNamespaces returns the namespaces, other than the one of the
+// GenezioManager, the image pull Secret is replicated to
+func (r *GenezioManagerReconciler) pullSecretReplicaNamespaces(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) ([]string, error) {
+	replication := geneziomanager.Spec.ContainerRegistryConfig.PullSecretReplication
+	if replication == nil {
+		return nil, nil
+	}
+
+	namespaces := map[string]bool{}
+	for _, namespace := range replication.Namespaces {
+		namespaces[namespace] = true
+	}
+	if replication.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(replication.NamespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+		list := &corev1.NamespaceList{}
+		if err := r.List(ctx, list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, err
+		}
+		for _, namespace := range list.Items {
+			// Terminating namespaces reject new objects
+			if namespace.Status.Phase != corev1.NamespaceTerminating {
+				namespaces[namespace.Name] = true
+			}
+		}
+	}
+	delete(namespaces, geneziomanager.Namespace)
+
+	names := make([]string, 0, len(namespaces))
+	for namespace := range namespaces {
+		names = append(names, namespace)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// reconcileRegistryPullSecret applies the image pull Secret generated from the registry
+// credentials, used by the genezio-manager pod, and its copies in the namespaces
+// selected by spec.containerRegistryConfig.pullSecretReplication. The copies cannot
+// be garbage collected through an owner reference: the ones no longer selected are
+// deleted here and the rest by the finalizer.
+func (r *GenezioManagerReconciler) reconcileRegistryPullSecret(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) error {
+	log := log.FromContext(ctx)
+	registryConfig := geneziomanager.Spec.ContainerRegistryConfig
+	if !usesRegistryPullSecret(registryConfig) {
+		return r.deleteRegistryPullSecretReplicas(ctx, geneziomanager, nil)
+	}
+
+	password, err := r.resolveCredential(ctx, geneziomanager.Namespace, credentialsFor(geneziomanager).registryPassword)
+	if err != nil {
+		return err
+	}
+	dockerConfig, err := dockerConfigJSON(registryConfig.URL, registryConfig.Username, password)
+	if err != nil {
+		return err
+	}
+
+	secret := registryPullSecretFor(geneziomanager, geneziomanager.Namespace, dockerConfig)
+	// Set the ownerRef for the Secret so it is garbage collected with the custom resource
+	if err := ctrl.SetControllerReference(geneziomanager, secret, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Patch(ctx, secret, client.Apply, client.ForceOwnership, client.FieldOwner(fieldManager)); err != nil {
+		log.Error(err, "Failed to apply Secret", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
+		return err
+	}
+
+	namespaces, err := r.pullSecretReplicaNamespaces(ctx, geneziomanager)
+	if err != nil {
+		return err
+	}
+	keep := map[string]bool{}
+	for _, namespace := range namespaces {
+		replica := registryPullSecretFor(geneziomanager, namespace, dockerConfig)
+
+		// Never take over a Secret which was not created for this GenezioManager
+		found := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: replica.Name, Namespace: namespace}, found)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		if err == nil && found.Labels[ownerNamespaceLabel] != geneziomanager.Namespace {
+			log.Info("Not replicating the image pull Secret over an existing Secret",
+				"Secret.Namespace", namespace, "Secret.Name", replica.Name)
+			continue
+		}
+
+		if err := r.Patch(ctx, replica, client.Apply, client.ForceOwnership, client.FieldOwner(fieldManager)); err != nil {
+			log.Error(err, "Failed to apply Secret", "Secret.Namespace", replica.Namespace, "Secret.Name", replica.Name)
+			return err
+		}
+		keep[namespace] = true
+	}
+	return r.deleteRegistryPullSecretReplicas(ctx, geneziomanager, keep)
+}
+
+// deleteRegistryPullSecretReplicas deletes the copies of the image pull Secret of the
+// GenezioManager, except the ones living in the namespaces to keep
+func (r *GenezioManagerReconciler) deleteRegistryPullSecretReplicas(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager, keepNamespaces map[string]bool) error {
+	log := log.FromContext(ctx)
+	secrets := &corev1.SecretList{}
+	ls := crossNamespaceLabelsForGenezioManager(geneziomanager, componentRegistryPullSecret)
+	if err := r.List(ctx, secrets, client.MatchingLabels(ls)); err != nil {
+		return err
+	}
+
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if keepNamespaces[secret.Namespace] {
+			continue
+		}
+		log.Info("Deleting image pull Secret replica", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
+		if err := r.Delete(ctx, secret); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// requestsForNamespace maps a namespace to the GenezioManagers replicating their image
+// pull Secret to it, so namespaces created or labelled later get a copy
+func (r *GenezioManagerReconciler) requestsForNamespace(ctx context.Context, obj client.Object) []reconcile.Request {
+	list := &initv1alpha1.GenezioManagerList{}
+	if err := r.List(ctx, list); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list GenezioManagers for namespace", "Namespace", obj.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, geneziomanager := range list.Items {
+		replication := geneziomanager.Spec.ContainerRegistryConfig.PullSecretReplication
+		if replication == nil {
+			continue
+		}
+		selected := false
+		for _, namespace := range replication.Namespaces {
+			selected = selected || namespace == obj.GetName()
+		}
+		// Namespaces leaving the selector are mapped too, so their copy is deleted
+		if replication.NamespaceSelector != nil {
+			selected = true
+		}
+		if selected {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKey{
+				Name:      geneziomanager.Name,
+				Namespace: geneziomanager.Namespace,
+			}})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// registryAPITimeout bounds every call made by the operator to the container registry
+const registryAPITimeout = 10 * time.Second
+
+// Reasons used on the RegistryReady condition
+const (
+	reasonRegistryAuthenticated = "Authenticated"
+	reasonRegistryUnauthorized  = "RegistryUnauthorized"
+	reasonRegistryUnreachable   = "RegistryUnreachable"
+)
+
+// errRegistryUnauthorized is returned when the registry rejects the credentials
+var errRegistryUnauthorized = errors.New("the container registry rejected the credentials")
+
+// registryBaseURL returns the URL of the registry API, defaulting to HTTPS when the
+// spec gives a bare host like docker does. The API of Docker Hub is served by
+// registry-1.docker.io whichever of its hosts is given.
+func registryBaseURL(registryURL string) string {
+	if registryHost(registryURL) == "docker.io" {
+		return "https://registry-1.docker.io"
+	}
+	if !strings.HasPrefix(registryURL, "https://") && !strings.HasPrefix(registryURL, "http://") {
+		registryURL = "https://" + registryURL
+	}
+	return strings.TrimSuffix(registryURL, "/")
+}
+
+// parseAuthChallenge splits a WWW-Authenticate header such as
+// `Bearer realm="https://auth.example.com/token",service="registry"` into its
+// scheme and parameters. Quoted values may contain commas, e.g. the actions of
+// scope="repository:genezio/manager:pull,push", and backslash-escaped quotes.
+func parseAuthChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := map[string]string{}
+	for rest != "" {
+		var key, value string
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " ")
+
+		if strings.HasPrefix(rest, `"`) {
+			var quoted strings.Builder
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				quoted.WriteByte(rest[i])
+			}
+			value = quoted.String()
+			// Whatever follows the closing quote up to the next comma is dropped
+			_, rest, _ = strings.Cut(rest[i:], ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+		}
+		if key != "" {
+			params[key] = value
+		}
+	}
+	return strings.ToLower(scheme), params
+}
+
+// registryGet sends a GET request with the given authentication and returns the response,
+// whose body has already been closed unless out is set and the request succeeded
+func registryGet(ctx context.Context, endpoint string, authenticate func(*http.Request),
+	out interface{}) (*http.Response, error) {
+	return registryDo(ctx, http.MethodGet, endpoint, authenticate, out)
+}
+
+// registryDo is registryGet for any method
+func registryDo(ctx context.Context, method, endpoint string, authenticate func(*http.Request),
+	out interface{}) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if authenticate != nil {
+		authenticate(req)
+	}
+
+	resp, err := (&http.Client{Timeout: registryAPITimeout}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// registryLogin performs the handshake of `docker login` against a Docker Registry
+// HTTP API v2: the /v2/ endpoint answers with a challenge, either basic auth or a
+// bearer token to fetch from the given realm, which has to be met with the credentials.
+func registryLogin(ctx context.Context, registryURL, username, password string) error {
+	endpoint := registryBaseURL(registryURL) + "/v2/"
+	resp, err := registryGet(ctx, endpoint, nil, nil)
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// The registry allows anonymous access
+		return nil
+	case http.StatusUnauthorized:
+	default:
+		return fmt.Errorf("GET %s returned %s", endpoint, resp.Status)
+	}
+
+	basicAuth := func(req *http.Request) { req.SetBasicAuth(username, password) }
+	scheme, params := parseAuthChallenge(resp.Header.Get("WWW-Authenticate"))
+	switch {
+	case username == "":
+		return errRegistryUnauthorized
+	case scheme == "basic":
+		resp, err = registryGet(ctx, endpoint, basicAuth, nil)
+	case scheme == "bearer" && params["realm"] != "":
+		realm, parseErr := url.Parse(params["realm"])
+		if parseErr != nil {
+			return fmt.Errorf("invalid token realm %q: %w", params["realm"], parseErr)
+		}
+		query := realm.Query()
+		if params["service"] != "" {
+			query.Set("service", params["service"])
+		}
+		query.Set("account", username)
+		realm.RawQuery = query.Encode()
+
+		token := struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}{}
+		resp, err = registryGet(ctx, realm.String(), basicAuth, &token)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			break
+		}
+		bearer := token.Token
+		if bearer == "" {
+			bearer = token.AccessToken
+		}
+		resp, err = registryGet(ctx, endpoint, func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}, nil)
+	default:
+		return fmt.Errorf("unsupported authentication challenge %q from %s", resp.Header.Get("WWW-Authenticate"), endpoint)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return errRegistryUnauthorized
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("GET %s returned %s", resp.Request.URL, resp.Status)
+	}
+	return nil
+}
+
+// checkRegistry logs into the container registry with the credentials of the spec and
+// reports the outcome on the RegistryReady condition. A failing registry does not stop
+// the reconciliation, the genezio-manager only needs it to push the images it builds.
+func (r *GenezioManagerReconciler) checkRegistry(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) error {
+	log := log.FromContext(ctx)
+	registryConfig := geneziomanager.Spec.ContainerRegistryConfig
+	if registryConfig.URL == "" {
+		meta.RemoveStatusCondition(&geneziomanager.Status.Conditions, typeRegistryReadyGenezioManager)
+		return nil
+	}
+
+	password, err := r.resolveCredential(ctx, geneziomanager.Namespace, credentialsFor(geneziomanager).registryPassword)
+	if err != nil {
+		return err
+	}
+
+	condition := metav1.Condition{Type: typeRegistryReadyGenezioManager, Status: metav1.ConditionTrue,
+		Reason: reasonRegistryAuthenticated,
+		Message: fmt.Sprintf("Logged into the container registry %s as %s",
+			registryConfig.URL, registryConfig.Username)}
+	err = registryLogin(ctx, registryConfig.URL, registryConfig.Username, password)
+	switch {
+	case errors.Is(err, errRegistryUnauthorized):
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonRegistryUnauthorized
+		condition.Message = fmt.Sprintf("The container registry %s rejected the credentials of %s",
+			registryConfig.URL, registryConfig.Username)
+	case err != nil:
+		log.Info("Failed to reach the container registry", "url", registryConfig.URL, "error", err.Error())
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonRegistryUnreachable
+		condition.Message = fmt.Sprintf("Failed to reach the container registry %s: %s", registryConfig.URL, err)
+	}
+	meta.SetStatusCondition(&geneziomanager.Status.Conditions, condition)
+	return nil
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDockerConfigJSON(t *testing.T) {
+	raw, err := dockerConfigJSON("https://registry.example.com/", "genezio", "s3cret")
+	if err != nil {
+		t.Fatalf("unexpected error rendering the docker config: %v", err)
+	}
+
+	var config struct {
+		Auths map[string]struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Auth     string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(raw, &config); err != nil {
+		t.Fatalf("invalid docker config %s: %v", raw, err)
+	}
+	entry, ok := config.Auths["registry.example.com"]
+	if !ok || len(config.Auths) != 1 {
+		t.Fatalf("expected a single entry for registry.example.com, got %s", raw)
+	}
+	// base64 of genezio:s3cret
+	if entry.Username != "genezio" || entry.Password != "s3cret" || entry.Auth != "Z2VuZXppbzpzM2NyZXQ=" {
+		t.Fatalf("unexpected auth entry %s", raw)
+	}
+}
+
+func TestRegistryHost(t *testing.T) {
+	tests := []struct {
+		url     string
+		host    string
+		key     string
+		baseURL string
+	}{
+		{url: "registry.example.com", host: "registry.example.com", key: "registry.example.com",
+			baseURL: "https://registry.example.com"},
+		{url: "https://registry.example.com:5000/", host: "registry.example.com:5000",
+			key: "registry.example.com:5000"},
+		{url: "http://localhost:5000", host: "localhost:5000", key: "localhost:5000",
+			baseURL: "http://localhost:5000"},
+		{url: "docker.io", host: "docker.io", key: dockerHubConfigKey,
+			baseURL: "https://registry-1.docker.io"},
+		{url: "https://docker.io", host: "docker.io", key: dockerHubConfigKey},
+		{url: "index.docker.io", host: "docker.io", key: dockerHubConfigKey},
+		{url: "https://index.docker.io/v1/", host: "docker.io", key: dockerHubConfigKey,
+			baseURL: "https://registry-1.docker.io"},
+		{url: "registry-1.docker.io", host: "docker.io", key: dockerHubConfigKey},
+	}
+	for _, tt := range tests {
+		if host := registryHost(tt.url); host != tt.host {
+			t.Errorf("registryHost(%q) = %q, want %q", tt.url, host, tt.host)
+		}
+		if key := dockerConfigKey(tt.url); key != tt.key {
+			t.Errorf("dockerConfigKey(%q) = %q, want %q", tt.url, key, tt.key)
+		}
+		if tt.baseURL != "" && registryBaseURL(tt.url) != tt.baseURL {
+			t.Errorf("registryBaseURL(%q) = %q, want %q", tt.url, registryBaseURL(tt.url), tt.baseURL)
+		}
+	}
+}
+
+func TestValidateRegistryConfig(t *testing.T) {
+	registryConfig := initv1alpha1.ContainerRegistryConfig{
+		URL:      "registry.example.com",
+		Username: "genezio",
+		PullSecretReplication: &initv1alpha1.PullSecretReplication{
+			NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+				Key:      "genezio.com/apps",
+				Operator: metav1.LabelSelectorOpIn,
+			}}},
+		},
+	}
+	var specErr *specError
+	if err := validateRegistryConfig(registryConfig); !errors.As(err, &specErr) ||
+		specErr.Reason != reasonInvalidRegistryConfig {
+		t.Errorf("expected an %s error for an In requirement without values, got %v", reasonInvalidRegistryConfig, err)
+	}
+
+	registryConfig.PullSecretReplication.NamespaceSelector.MatchExpressions[0].Values = []string{"true"}
+	if err := validateRegistryConfig(registryConfig); err != nil {
+		t.Errorf("unexpected error for a valid selector: %v", err)
+	}
+}
+
+func TestRegistryLogin(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer registry-token" {
+			w.Header().Set("WWW-Authenticate",
+				fmt.Sprintf(`Bearer realm="%s/token",service="registry.example.com"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		if username != "genezio" || password != "s3cret" ||
+			r.URL.Query().Get("service") != "registry.example.com" || r.URL.Query().Get("account") != "genezio" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"token":"registry-token"}`))
+	})
+
+	ctx := context.Background()
+	if err := registryLogin(ctx, server.URL, "genezio", "s3cret"); err != nil {
+		t.Errorf("unexpected error logging in with a bearer token: %v", err)
+	}
+	if err := registryLogin(ctx, server.URL, "genezio", "wrong"); !errors.Is(err, errRegistryUnauthorized) {
+		t.Errorf("expected errRegistryUnauthorized for a wrong password, got %v", err)
+	}
+	if err := registryLogin(ctx, server.URL, "", ""); !errors.Is(err, errRegistryUnauthorized) {
+		t.Errorf("expected errRegistryUnauthorized without credentials, got %v", err)
+	}
+}
+
+func TestParseAuthChallenge(t *testing.T) {
+	scheme, params := parseAuthChallenge(
+		`Bearer realm="https://auth.docker.io/token",service="registry.docker.io"`)
+	if scheme != "bearer" || params["realm"] != "https://auth.docker.io/token" ||
+		params["service"] != "registry.docker.io" {
+		t.Fatalf("unexpected challenge %s %v", scheme, params)
+	}
+
+	// Commas inside quoted values do not separate parameters
+	scheme, params = parseAuthChallenge(`Bearer realm="https://ghcr.io/token", ` +
+		`scope="repository:genezio/manager:pull,push",service=ghcr.io,error="insufficient \"scope\""`)
+	if scheme != "bearer" || len(params) != 4 || params["realm"] != "https://ghcr.io/token" ||
+		params["scope"] != "repository:genezio/manager:pull,push" || params["service"] != "ghcr.io" ||
+		params["error"] != `insufficient "scope"` {
+		t.Fatalf("unexpected challenge %s %v", scheme, params)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// secretHashAnnotation is stamped on the pod template with the hash of the Secrets
+// used by the genezio-manager, so that changing one of them rolls the Deployment
+const secretHashAnnotation = "init.genezio.com/secret-hash"
+
+// referencedSecretsIndex is the field index of the GenezioManagers by the names of
+// the Secrets referenced by their spec
+const referencedSecretsIndex = "spec.referencedSecrets"
+
+// referencedSecretNames returns the names of the Secrets referenced by the spec of the
+// GenezioManager. It is the field indexer of referencedSecretsIndex.
+func referencedSecretNames(obj client.Object) []string {
+	geneziomanager, ok := obj.(*initv1alpha1.GenezioManager)
+	if !ok {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, cred := range credentialsFor(geneziomanager).all() {
+		if cred.secretName != "" {
+			names[cred.secretName] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// podSecretNames returns the names of the Secrets a pod reads its environment and
+// volumes from
+func podSecretNames(spec corev1.PodSpec) []string {
+	names := map[string]bool{}
+	for _, container := range append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...) {
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				names[env.ValueFrom.SecretKeyRef.Name] = true
+			}
+		}
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil {
+				names[envFrom.SecretRef.Name] = true
+			}
+		}
+	}
+	for _, volume := range spec.Volumes {
+		if volume.Secret != nil {
+			names[volume.Secret.SecretName] = true
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil {
+					names[source.Secret.Name] = true
+				}
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// hashSecrets returns a digest of the content of the Secrets, independent of the
+// order of their keys
+func hashSecrets(secrets []corev1.Secret) string {
+	hash := sha256.New()
+	for _, secret := range secrets {
+		keys := make([]string, 0, len(secret.Data))
+		for key := range secret.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		hash.Write([]byte(secret.Name))
+		hash.Write([]byte{0})
+		for _, key := range keys {
+			hash.Write([]byte(key))
+			hash.Write([]byte{0})
+			hash.Write(secret.Data[key])
+			hash.Write([]byte{0})
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// stampSecretHash annotates the pod template of the Deployment with the hash of the
+// Secrets it references. The kubelet does not refresh environment variables read
+// from a Secret, so a new hash rolls the pods for them to pick up the new values.
+func (r *GenezioManagerReconciler) stampSecretHash(ctx context.Context, template *corev1.PodTemplateSpec,
+	namespace string) error {
+	var secrets []corev1.Secret
+	for _, name := range podSecretNames(template.Spec) {
+		secret := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, secret)
+		if apierrors.IsNotFound(err) {
+			// Managed Secrets may not be created yet, the pods wait for them
+			continue
+		} else if err != nil {
+			return err
+		}
+		secrets = append(secrets, *secret)
+	}
+
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[secretHashAnnotation] = hashSecrets(secrets)
+	return nil
+}
+
+// requestsForSecret maps a Secret to the GenezioManagers of its namespace which
+// reference it, found through referencedSecretsIndex
+func (r *GenezioManagerReconciler) requestsForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	list := &initv1alpha1.GenezioManagerList{}
+	if err := r.List(ctx, list, client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{referencedSecretsIndex: obj.GetName()}); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list GenezioManagers for Secret",
+			"Secret.Namespace", obj.GetNamespace(), "Secret.Name", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for _, geneziomanager := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKey{
+			Name:      geneziomanager.Name,
+			Namespace: geneziomanager.Namespace,
+		}})
+	}
+	return requests
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReferencedSecretNames(t *testing.T) {
+	geneziomanager := &initv1alpha1.GenezioManager{Spec: initv1alpha1.GenezioManagerSpec{
+		GitConfig: initv1alpha1.GitConfig{
+			Provider: "gitea",
+			Gitea: initv1alpha1.GiteaProvider{
+				Username:        "genezio",
+				TokenSecretName: "credentials",
+				TokenSecretKey:  "gitea",
+			},
+		},
+		ContainerRegistryConfig: initv1alpha1.ContainerRegistryConfig{
+			PasswordSecretName: "registry",
+			PasswordSecretKey:  "password",
+		},
+		ArgoCDConfig: initv1alpha1.ArgoCDConfig{
+			PasswordSecretName: "credentials",
+			PasswordSecretKey:  "argocd",
+		},
+	}}
+	if names := referencedSecretNames(geneziomanager); !reflect.DeepEqual(names, []string{"credentials", "registry"}) {
+		t.Fatalf("expected the credentials and registry Secrets, got %v", names)
+	}
+}
+
+func TestHashSecrets(t *testing.T) {
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "credentials"},
+		Data:       map[string][]byte{"token": []byte("a"), "password": []byte("b")},
+	}
+	hash := hashSecrets([]corev1.Secret{secret})
+	if hash != hashSecrets([]corev1.Secret{secret}) {
+		t.Fatalf("the hash of the same Secrets is not stable")
+	}
+
+	secret.Data = map[string][]byte{"token": []byte("c"), "password": []byte("b")}
+	if hash == hashSecrets([]corev1.Secret{secret}) {
+		t.Fatalf("the hash did not change with the content of the Secret")
+	}
+}
+
+func TestPodSecretNames(t *testing.T) {
+	spec := corev1.PodSpec{
+		Containers: []corev1.Container{{
+			Env: []corev1.EnvVar{
+				{Name: "PLAIN", Value: "value"},
+				{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "token"}, Key: "token"}}},
+			},
+		}},
+		Volumes: []corev1.Volume{{
+			Name: "ssh",
+			VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{{Secret: &corev1.SecretProjection{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "ssh"}}}},
+			}},
+		}},
+	}
+	if names := podSecretNames(spec); !reflect.DeepEqual(names, []string{"ssh", "token"}) {
+		t.Fatalf("expected the ssh and token Secrets, got %v", names)
+	}
+}
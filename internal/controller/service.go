@@ -0,0 +1,182 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// containerPortName is the name of the port of the genezio-manager container
+const containerPortName = "genezio-manager"
+
+// servicePortForGenezioManager returns the port exposed by the Service
+func servicePortForGenezioManager(geneziomanager *initv1alpha1.GenezioManager) int32 {
+	if geneziomanager.Spec.Service.Port != 0 {
+		return geneziomanager.Spec.Service.Port
+	}
+	return geneziomanager.Spec.ContainerPort
+}
+
+// serviceForGenezioManager returns a GenezioManager Service object
+func (r *GenezioManagerReconciler) serviceForGenezioManager(
+	geneziomanager *initv1alpha1.GenezioManager) (*corev1.Service, error) {
+	serviceType := geneziomanager.Spec.Service.Type
+	if serviceType == "" {
+		serviceType = corev1.ServiceTypeClusterIP
+	}
+
+	svc := &corev1.Service{
+		// The type information is required by server-side apply
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Service",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        geneziomanager.Name,
+			Namespace:   geneziomanager.Namespace,
+			Labels:      labelsForGenezioManager(geneziomanager),
+			Annotations: geneziomanager.Spec.Service.Annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     serviceType,
+			Selector: selectorLabelsForGenezioManager(geneziomanager.Name),
+			Ports: []corev1.ServicePort{{
+				Name:       containerPortName,
+				Protocol:   corev1.ProtocolTCP,
+				Port:       servicePortForGenezioManager(geneziomanager),
+				TargetPort: intstr.FromString(containerPortName),
+			}},
+		},
+	}
+
+	// Set the ownerRef for the Service so it is garbage collected with the custom resource
+	if err := ctrl.SetControllerReference(geneziomanager, svc, r.Scheme); err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
+
+// ingressForGenezioManager returns a GenezioManager Ingress object
+func (r *GenezioManagerReconciler) ingressForGenezioManager(
+	geneziomanager *initv1alpha1.GenezioManager) (*networkingv1.Ingress, error) {
+	spec := geneziomanager.Spec.Ingress
+	pathType := networkingv1.PathTypePrefix
+
+	ing := &networkingv1.Ingress{
+		// The type information is required by server-side apply
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: networkingv1.SchemeGroupVersion.String(),
+			Kind:       "Ingress",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        geneziomanager.Name,
+			Namespace:   geneziomanager.Namespace,
+			Labels:      labelsForGenezioManager(geneziomanager),
+			Annotations: spec.Annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{
+				Host: spec.Host,
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{
+							Path:     "/",
+							PathType: &pathType,
+							Backend: networkingv1.IngressBackend{
+								Service: &networkingv1.IngressServiceBackend{
+									Name: geneziomanager.Name,
+									Port: networkingv1.ServiceBackendPort{
+										Number: servicePortForGenezioManager(geneziomanager),
+									},
+								},
+							},
+						}},
+					},
+				},
+			}},
+		},
+	}
+	if spec.IngressClassName != "" {
+		ing.Spec.IngressClassName = &spec.IngressClassName
+	}
+	if spec.TLSSecretName != "" {
+		ing.Spec.TLS = []networkingv1.IngressTLS{{
+			Hosts:      []string{spec.Host},
+			SecretName: spec.TLSSecretName,
+		}}
+	}
+
+	// Set the ownerRef for the Ingress so it is garbage collected with the custom resource
+	if err := ctrl.SetControllerReference(geneziomanager, ing, r.Scheme); err != nil {
+		return nil, err
+	}
+	return ing, nil
+}
+
+// reconcileNetworking applies the Service of the GenezioManager and the Ingress
+// when one is requested. An Ingress previously created by the operator is removed
+// once spec.ingress is unset.
+func (r *GenezioManagerReconciler) reconcileNetworking(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager) error {
+	log := log.FromContext(ctx)
+
+	svc, err := r.serviceForGenezioManager(geneziomanager)
+	if err != nil {
+		return err
+	}
+	if err := r.Patch(ctx, svc, client.Apply, client.ForceOwnership, client.FieldOwner(fieldManager)); err != nil {
+		log.Error(err, "Failed to apply Service", "Service.Namespace", svc.Namespace, "Service.Name", svc.Name)
+		return err
+	}
+
+	if geneziomanager.Spec.Ingress != nil {
+		ing, err := r.ingressForGenezioManager(geneziomanager)
+		if err != nil {
+			return err
+		}
+		if err := r.Patch(ctx, ing, client.Apply, client.ForceOwnership, client.FieldOwner(fieldManager)); err != nil {
+			log.Error(err, "Failed to apply Ingress", "Ingress.Namespace", ing.Namespace, "Ingress.Name", ing.Name)
+			return err
+		}
+		return nil
+	}
+
+	found := &networkingv1.Ingress{}
+	err = r.Get(ctx, types.NamespacedName{Name: geneziomanager.Name, Namespace: geneziomanager.Namespace}, found)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(found, geneziomanager) {
+		return nil
+	}
+	log.Info("Deleting Ingress no longer requested", "Ingress.Namespace", found.Namespace, "Ingress.Name", found.Name)
+	if err := r.Delete(ctx, found); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
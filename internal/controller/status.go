@@ -0,0 +1,177 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	initv1alpha1 "github.com/Genez-io/genezio-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// failingContainerReasons are the waiting reasons of a container which will not
+// recover without an intervention, e.g. a new image or a fixed Secret.
+var failingContainerReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"RunContainerError":          true,
+}
+
+// findDeploymentCondition returns the condition of the given type of a Deployment, if any
+func findDeploymentCondition(dep *appsv1.Deployment,
+	conditionType appsv1.DeploymentConditionType) *appsv1.DeploymentCondition {
+	for i := range dep.Status.Conditions {
+		if dep.Status.Conditions[i].Type == conditionType {
+			return &dep.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// failingContainer returns the first container of the operand pods which is stuck
+// in a state it cannot recover from, as a condition reason and message.
+func (r *GenezioManagerReconciler) failingContainer(ctx context.Context,
+	dep *appsv1.Deployment) (string, string, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(dep.Namespace),
+		client.MatchingLabels(dep.Spec.Selector.MatchLabels)); err != nil {
+		return "", "", err
+	}
+
+	for _, pod := range pods.Items {
+		statuses := append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...)
+		statuses = append(statuses, pod.Status.ContainerStatuses...)
+		for _, cs := range statuses {
+			if cs.State.Waiting != nil && failingContainerReasons[cs.State.Waiting.Reason] {
+				return cs.State.Waiting.Reason, fmt.Sprintf("Container %s of pod %s is in %s: %s",
+					cs.Name, pod.Name, cs.State.Waiting.Reason, cs.State.Waiting.Message), nil
+			}
+		}
+	}
+	return "", "", nil
+}
+
+// setStatusFromDeployment fills the status of the GenezioManager from the live
+// Deployment and its pods. The Available, Progressing and Degraded conditions are
+// derived from the conditions of the Deployment and the state of the containers.
+func (r *GenezioManagerReconciler) setStatusFromDeployment(ctx context.Context,
+	geneziomanager *initv1alpha1.GenezioManager, dep *appsv1.Deployment) error {
+	status := &geneziomanager.Status
+	status.ObservedGeneration = geneziomanager.Generation
+	status.Replicas = dep.Status.Replicas
+	status.ReadyReplicas = dep.Status.ReadyReplicas
+	status.UpdatedReplicas = dep.Status.UpdatedReplicas
+	status.AvailableReplicas = dep.Status.AvailableReplicas
+	desiredReplicas := dep.Status.Replicas
+	if dep.Spec.Replicas != nil {
+		desiredReplicas = *dep.Spec.Replicas
+	}
+	status.Ready = fmt.Sprintf("%d/%d", dep.Status.ReadyReplicas, desiredReplicas)
+	status.BaseURL = baseURLForGenezioManager(geneziomanager.Spec)
+	status.SecretHash = dep.Spec.Template.Annotations[secretHashAnnotation]
+	if len(dep.Spec.Template.Spec.Containers) > 0 {
+		status.Image = dep.Spec.Template.Spec.Containers[0].Image
+	}
+
+	// Progressing mirrors the rollout of the Deployment
+	rolledOut := dep.Status.ObservedGeneration >= dep.Generation &&
+		dep.Spec.Replicas != nil && dep.Status.UpdatedReplicas == *dep.Spec.Replicas &&
+		dep.Status.Replicas == dep.Status.UpdatedReplicas
+	progressing := findDeploymentCondition(dep, appsv1.DeploymentProgressing)
+	switch {
+	case progressing != nil && progressing.Reason == "ProgressDeadlineExceeded":
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: typeProgressingGenezioManager,
+			Status: metav1.ConditionFalse, Reason: progressing.Reason, Message: progressing.Message})
+	case rolledOut && (progressing == nil || progressing.Reason == "NewReplicaSetAvailable"):
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: typeProgressingGenezioManager,
+			Status: metav1.ConditionFalse, Reason: "RolloutComplete",
+			Message: fmt.Sprintf("Deployment for custom resource (%s) is rolled out", geneziomanager.Name)})
+	default:
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: typeProgressingGenezioManager,
+			Status: metav1.ConditionTrue, Reason: "RollingOut",
+			Message: fmt.Sprintf("Deployment for custom resource (%s) is rolling out: %d of %d pods updated",
+				geneziomanager.Name, dep.Status.UpdatedReplicas, dep.Status.Replicas)})
+	}
+
+	// Degraded reports pods which will not become ready on their own
+	reason, message, err := r.failingContainer(ctx, dep)
+	if err != nil {
+		return err
+	}
+	switch {
+	case reason != "":
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: typeDegradedGenezioManager,
+			Status: metav1.ConditionTrue, Reason: reason, Message: message})
+	case progressing != nil && progressing.Reason == "ProgressDeadlineExceeded":
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: typeDegradedGenezioManager,
+			Status: metav1.ConditionTrue, Reason: progressing.Reason, Message: progressing.Message})
+	default:
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: typeDegradedGenezioManager,
+			Status: metav1.ConditionFalse, Reason: "Reconciling",
+			Message: fmt.Sprintf("Pods of custom resource (%s) are healthy", geneziomanager.Name)})
+	}
+
+	// Available requires the Deployment to report minimum availability, no pod to be failing
+	// and the external systems the genezio-manager depends on to be ready
+	available := findDeploymentCondition(dep, appsv1.DeploymentAvailable)
+	dependency := notReadyDependency(status.Conditions)
+	switch {
+	case reason != "":
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: typeAvailableGenezioManager,
+			Status: metav1.ConditionFalse, Reason: reason, Message: message})
+	case dependency != nil:
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: typeAvailableGenezioManager,
+			Status: metav1.ConditionFalse, Reason: dependency.Reason,
+			Message: fmt.Sprintf("%s is false: %s", dependency.Type, dependency.Message)})
+	case available != nil && available.Status == corev1.ConditionTrue && dep.Status.AvailableReplicas > 0:
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: typeAvailableGenezioManager,
+			Status: metav1.ConditionTrue, Reason: "MinimumReplicasAvailable",
+			Message: fmt.Sprintf("Deployment for custom resource (%s) has %d available replicas",
+				geneziomanager.Name, dep.Status.AvailableReplicas)})
+	case available != nil:
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: typeAvailableGenezioManager,
+			Status: metav1.ConditionFalse, Reason: available.Reason, Message: available.Message})
+	default:
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: typeAvailableGenezioManager,
+			Status: metav1.ConditionFalse, Reason: "Reconciling",
+			Message: fmt.Sprintf("Waiting for the Deployment of custom resource (%s) to report its status",
+				geneziomanager.Name)})
+	}
+	return nil
+}
+
+// requestsForPod maps a pod of the operand to the GenezioManager which owns it
+func requestsForPod(_ context.Context, obj client.Object) []reconcile.Request {
+	labels := obj.GetLabels()
+	if labels["app.kubernetes.io/name"] != "GenezioManager" || labels["app.kubernetes.io/instance"] == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{
+		Name:      labels["app.kubernetes.io/instance"],
+		Namespace: obj.GetNamespace(),
+	}}}
+}